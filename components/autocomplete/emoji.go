@@ -0,0 +1,92 @@
+package autocomplete
+
+import (
+	"context"
+
+	"github.com/diamondburned/chatkit/md"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotk4/pkg/pango"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+)
+
+// EmojiMinLength is the minimum length of the query before EmojiSearcher
+// returns any results. It avoids popping up on things like "12:3".
+const EmojiMinLength = 2
+
+// EmojiSearcher is a Searcher that searches emojis by their shortcodes when
+// the user types a colon. Use InsertEmoji as the SelectedFunc to replace the
+// shortcode with the selected emoji.
+type EmojiSearcher struct{}
+
+var _ Searcher = EmojiSearcher{}
+
+// Rune implements Searcher.
+func (EmojiSearcher) Rune() rune { return ':' }
+
+// Search implements Searcher.
+func (EmojiSearcher) Search(ctx context.Context, str string) []Data {
+	if len(str) < EmojiMinLength {
+		return nil
+	}
+
+	emojis := md.SearchEmojis(str, MaxResults)
+
+	data := make([]Data, len(emojis))
+	for i, emoji := range emojis {
+		data[i] = EmojiData(emoji)
+	}
+
+	return data
+}
+
+// EmojiData is the Data returned by EmojiSearcher.
+type EmojiData md.EmojiShortcode
+
+var emojiRowCSS = cssutil.Applier("autocomplete-emoji", `
+	.autocomplete-emoji-glyph {
+		min-width: 1.5em;
+	}
+`)
+
+// Row implements Data.
+func (d EmojiData) Row(ctx context.Context) *gtk.ListBoxRow {
+	glyph := gtk.NewLabel(d.Unicode)
+	glyph.AddCSSClass("autocomplete-emoji-glyph")
+
+	shortcode := gtk.NewLabel(":" + d.Shortcode + ":")
+	shortcode.AddCSSClass("autocomplete-emoji-shortcode")
+	shortcode.SetXAlign(0)
+	shortcode.SetEllipsize(pango.EllipsizeMiddle)
+
+	box := gtk.NewBox(gtk.OrientationHorizontal, 6)
+	box.Append(glyph)
+	box.Append(shortcode)
+
+	row := gtk.NewListBoxRow()
+	row.SetChild(box)
+	emojiRowCSS(row)
+
+	return row
+}
+
+// InsertEmoji is a SelectedFunc that replaces the shortcode with the selected
+// emoji if the selected data is an EmojiData. It returns false otherwise, so
+// it can be added alongside other SelectedFuncs.
+func InsertEmoji(data SelectedData) bool {
+	emoji, ok := data.Data.(EmojiData)
+	if !ok {
+		return false
+	}
+
+	buffer := data.Bounds[0].Buffer()
+	buffer.BeginUserAction()
+	defer buffer.EndUserAction()
+
+	buffer.Delete(data.Bounds[0], data.Bounds[1])
+	buffer.Insert(data.Bounds[0], emoji.Unicode)
+	// Insert only revalidates the given iterator, so keep the end iterator
+	// valid for the caller.
+	data.Bounds[1].Assign(data.Bounds[0])
+
+	return true
+}
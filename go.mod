@@ -11,9 +11,12 @@ require (
 	github.com/diamondburned/gotkit v0.0.0-20240614105032-cdfb37197d77
 	github.com/dustin/go-humanize v1.0.0
 	github.com/pkg/errors v0.9.1
+	github.com/rivo/uniseg v0.4.7
 	github.com/yuin/goldmark v1.4.13
 	github.com/zalando/go-keyring v0.2.1
-	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519
+	golang.org/x/crypto v0.18.0
+	golang.org/x/net v0.20.0
+	golang.org/x/text v0.15.0
 	libdb.so/ctxt v0.0.0-20240229093153-2db38a5d3c12
 	libdb.so/go-emoji v0.0.0-20240508073816-39776eee41ac
 )
@@ -30,5 +33,4 @@ require (
 	go4.org/unsafe/assume-no-moving-gc v0.0.0-20231121144256-b99613f794b6 // indirect
 	golang.org/x/image v0.0.0-20220902085622-e7cb96979f69 // indirect
 	golang.org/x/sync v0.0.0-20220722155255-886fb9371eb4 // indirect
-	golang.org/x/text v0.15.0 // indirect
 )
@@ -0,0 +1,121 @@
+package eventbus
+
+import (
+	"sync"
+	"time"
+
+	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
+)
+
+// CoalesceFrame is a special coalescing window that coalesces events until the
+// main loop is idle again, which is roughly once per frame. Events coalesced
+// this way are always delivered on the main loop.
+const CoalesceFrame time.Duration = -1
+
+// Coalescer coalesces values pushed under the same key, so that only the
+// latest value of each key is flushed. All keys share one window, which is
+// started by the first value pushed after the last flush; values pushed under
+// the same key before the window ends replace the pending one. A Coalescer is
+// safe for concurrent use by multiple goroutines.
+type Coalescer[K comparable, T any] struct {
+	mu        sync.Mutex
+	pending   map[K]T
+	keys      []K // keys in pending, in the order they were first pushed
+	scheduled bool
+	gen       uint64 // incremented on every flush to ignore stale windows
+
+	flush    func(K, T)
+	window   time.Duration
+	delivery Delivery
+}
+
+// NewCoalescer creates a new Coalescer that calls flush with the latest value
+// of each key once its window ends. If delivery is MainLoop or window is
+// CoalesceFrame, flush is called on the main loop. Otherwise, it is called in
+// its own goroutine. NewCoalescer panics if window is negative and not
+// CoalesceFrame.
+func NewCoalescer[K comparable, T any](delivery Delivery, window time.Duration, flush func(K, T)) *Coalescer[K, T] {
+	if window < 0 && window != CoalesceFrame {
+		panic("eventbus: negative coalescing window")
+	}
+
+	return &Coalescer[K, T]{
+		pending:  make(map[K]T),
+		flush:    flush,
+		window:   window,
+		delivery: delivery,
+	}
+}
+
+// Push pushes v under key, replacing any value of the same key that is still
+// pending.
+func (c *Coalescer[K, T]) Push(key K, v T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.pending[key]; !ok {
+		c.keys = append(c.keys, key)
+	}
+	c.pending[key] = v
+
+	if !c.scheduled {
+		c.scheduled = true
+		c.schedule()
+	}
+}
+
+func (c *Coalescer[K, T]) schedule() {
+	// The window may have been ended early by Flush, in which case this
+	// callback must not end the next window.
+	gen := c.gen
+	flush := func() { c.flushGen(gen) }
+
+	switch {
+	case c.window == CoalesceFrame:
+		coreglib.IdleAdd(flush)
+	case c.delivery == MainLoop:
+		coreglib.TimeoutAdd(uint(c.window.Milliseconds()), flush)
+	default:
+		time.AfterFunc(c.window, flush)
+	}
+}
+
+// Flush flushes all pending values immediately, in the order their keys were
+// first pushed. The current window ends.
+func (c *Coalescer[K, T]) Flush() {
+	c.mu.Lock()
+	keys, pending := c.take()
+	c.mu.Unlock()
+
+	c.flushAll(keys, pending)
+}
+
+func (c *Coalescer[K, T]) flushGen(gen uint64) {
+	c.mu.Lock()
+	if gen != c.gen {
+		c.mu.Unlock()
+		return
+	}
+	keys, pending := c.take()
+	c.mu.Unlock()
+
+	c.flushAll(keys, pending)
+}
+
+// take takes all pending values and ends the current window. c.mu must be
+// held.
+func (c *Coalescer[K, T]) take() ([]K, map[K]T) {
+	keys := c.keys
+	pending := c.pending
+	c.keys = nil
+	c.pending = make(map[K]T, len(pending))
+	c.scheduled = false
+	c.gen++
+	return keys, pending
+}
+
+func (c *Coalescer[K, T]) flushAll(keys []K, pending map[K]T) {
+	for _, key := range keys {
+		c.flush(key, pending[key])
+	}
+}
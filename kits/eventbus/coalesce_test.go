@@ -0,0 +1,100 @@
+package eventbus
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCoalescer(t *testing.T) {
+	type flushed struct {
+		key string
+		v   int
+	}
+
+	var got []flushed
+	c := NewCoalescer(Direct, time.Hour, func(key string, v int) {
+		got = append(got, flushed{key, v})
+	})
+
+	c.Push("typing", 1)
+	c.Push("presence", 1)
+	c.Push("typing", 2)
+	c.Push("presence", 2)
+	c.Push("typing", 3)
+	c.Flush()
+
+	c.Push("presence", 3)
+	c.Flush()
+
+	expect := []flushed{
+		{"typing", 3},
+		{"presence", 2},
+		{"presence", 3},
+	}
+
+	if !reflect.DeepEqual(got, expect) {
+		t.Fatalf("unexpected flushed values:\nexpected %v\ngot      %v", expect, got)
+	}
+}
+
+func TestCoalescerFlushEndsWindow(t *testing.T) {
+	const window = 200 * time.Millisecond
+
+	ch := make(chan int, 10)
+	c := NewCoalescer(Direct, window, func(key string, v int) { ch <- v })
+
+	c.Push("typing", 1)
+	c.Flush()
+	if v := <-ch; v != 1 {
+		t.Fatalf("expected 1 to be flushed, got %d", v)
+	}
+
+	// Start the second window halfway through the first one, whose timer is
+	// still pending.
+	time.Sleep(window / 2)
+	start := time.Now()
+	c.Push("typing", 2)
+
+	v := <-ch
+	if elapsed := time.Since(start); elapsed < window {
+		t.Fatalf("second batch was delivered after %v, before the window of %v ended", elapsed, window)
+	}
+	if v != 2 {
+		t.Fatalf("expected 2 to be flushed, got %d", v)
+	}
+}
+
+func TestNewCoalescerNegativeWindow(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("NewCoalescer did not panic on a negative window")
+		}
+	}()
+	NewCoalescer(Direct, -time.Second, func(string, int) {})
+}
+
+func TestBusCoalesce(t *testing.T) {
+	bus := New[string, int](Opts{
+		Delivery: Direct,
+		Coalesce: 100 * time.Millisecond,
+	})
+
+	ch := make(chan int, 10)
+	bus.Subscribe(context.Background(), "typing", func(v int) { ch <- v })
+
+	for i := 1; i <= 100; i++ {
+		bus.Publish("typing", i)
+	}
+
+	if v := <-ch; v != 100 {
+		t.Fatalf("expected only the latest event 100, got %d", v)
+	}
+
+	select {
+	case v := <-ch:
+		t.Fatalf("unexpected event %d", v)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,221 @@
+// Package eventbus implements a typed event bus on top of package registry.
+// Events are published under a topic, and only subscribers of that topic
+// receive them.
+//
+// By default, events are delivered on the GTK main loop, so it is safe to
+// publish events from any goroutine and touch widgets in the subscribers.
+package eventbus
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/diamondburned/chatkit/kits/registry"
+
+	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
+)
+
+// Delivery determines how and where events are delivered to subscribers.
+type Delivery uint8
+
+const (
+	// MainLoop delivers events asynchronously on the GTK main loop using
+	// glib.IdleAdd. It is the default.
+	MainLoop Delivery = iota
+	// Direct delivers events synchronously in the goroutine that publishes
+	// them.
+	Direct
+)
+
+// Opts contains options for Bus.
+type Opts struct {
+	// Delivery determines how events are delivered. Default is MainLoop.
+	Delivery Delivery
+	// Coalesce, if non-zero, coalesces events published under the same topic
+	// within the given window, so that only the latest event is delivered once
+	// the window ends. Use CoalesceFrame to coalesce events until the next
+	// main loop iteration. See Coalescer.
+	Coalesce time.Duration
+}
+
+// Bus is an event bus that publishes events of type T under topics of type K.
+// A Bus is safe for concurrent use by multiple goroutines.
+type Bus[K comparable, T any] struct {
+	mu     sync.Mutex
+	topics map[K]*registry.SyncRegistry
+	opts   Opts
+
+	coalescer *Coalescer[K, T]
+}
+
+// New creates a new Bus.
+func New[K comparable, T any](opts Opts) *Bus[K, T] {
+	b := &Bus[K, T]{
+		topics: make(map[K]*registry.SyncRegistry),
+		opts:   opts,
+	}
+	if opts.Coalesce != 0 {
+		// The coalescer already flushes in the right place, so deliver the
+		// events directly from there.
+		b.coalescer = NewCoalescer(opts.Delivery, opts.Coalesce, b.deliver)
+	}
+	return b
+}
+
+// Subscription is a handle to a subscriber of a Bus.
+type Subscription struct {
+	done  atomic.Bool
+	unsub func()
+	stop  func() bool // guarded by Bus.mu
+}
+
+// Unsubscribe removes the subscriber from the bus. Events that are still
+// pending delivery on the main loop are dropped. Calling Unsubscribe more than
+// once is a no-op.
+func (s *Subscription) Unsubscribe() {
+	if s.done.Swap(true) {
+		return
+	}
+	s.unsub()
+}
+
+// Done returns true if the subscription has ended, either because it was
+// unsubscribed, its context was cancelled or, for one-shot subscriptions, an
+// event was delivered.
+func (s *Subscription) Done() bool {
+	return s.done.Load()
+}
+
+type subscriber[T any] struct {
+	sub  *Subscription
+	f    func(T)
+	once bool
+}
+
+func (s *subscriber[T]) deliver(ev T) {
+	if s.once {
+		// Claim the only event, so racing deliveries are dropped.
+		if s.sub.done.Swap(true) {
+			return
+		}
+		s.sub.unsub()
+	} else if s.sub.done.Load() {
+		return
+	}
+
+	s.f(ev)
+}
+
+// Subscribe subscribes f to events published under topic. The subscription
+// ends when ctx is cancelled or when Unsubscribe is called.
+func (b *Bus[K, T]) Subscribe(ctx context.Context, topic K, f func(T)) *Subscription {
+	return b.subscribe(ctx, topic, f, false)
+}
+
+// SubscribeOnce is like Subscribe, except the subscription ends after the
+// first event is delivered.
+func (b *Bus[K, T]) SubscribeOnce(ctx context.Context, topic K, f func(T)) *Subscription {
+	return b.subscribe(ctx, topic, f, true)
+}
+
+func (b *Bus[K, T]) subscribe(ctx context.Context, topic K, f func(T), once bool) *Subscription {
+	sub := &Subscription{}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	reg, ok := b.topics[topic]
+	if !ok {
+		reg = registry.NewSync(1)
+		b.topics[topic] = reg
+	}
+
+	v := reg.Add(&subscriber[T]{sub: sub, f: f, once: once}, nil)
+	// unsub is the cleanup shared by Unsubscribe, the context and one-shot
+	// deliveries. It may run as soon as the context's AfterFunc is
+	// registered, so stop is only accessed while holding b.mu.
+	sub.unsub = func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if sub.stop != nil {
+			sub.stop()
+		}
+
+		v.Delete()
+		// Forget topics that nobody listens to anymore.
+		if reg.IsEmpty() && b.topics[topic] == reg {
+			delete(b.topics, topic)
+		}
+	}
+
+	if ctx.Done() != nil {
+		sub.stop = context.AfterFunc(ctx, sub.Unsubscribe)
+	}
+
+	return sub
+}
+
+// HasSubscribers returns true if topic has any subscriber.
+func (b *Bus[K, T]) HasSubscribers(topic K) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	reg, ok := b.topics[topic]
+	return ok && !reg.IsEmpty()
+}
+
+// Publish publishes ev to all subscribers of topic. Only subscribers that are
+// subscribed when Publish is called will receive the event, unless the bus
+// coalesces events, in which case the subscribers at the end of the window
+// receive it.
+func (b *Bus[K, T]) Publish(topic K, ev T) {
+	if b.coalescer != nil {
+		b.coalescer.Push(topic, ev)
+		return
+	}
+
+	b.mu.Lock()
+	reg, ok := b.topics[topic]
+	b.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	switch b.opts.Delivery {
+	case Direct:
+		deliverAll(reg, ev)
+
+	case MainLoop:
+		subs := make([]*subscriber[T], 0, reg.Len())
+		reg.Each(func(v, _ interface{}) { subs = append(subs, v.(*subscriber[T])) })
+
+		if len(subs) == 0 {
+			return
+		}
+
+		coreglib.IdleAdd(func() {
+			for _, sub := range subs {
+				sub.deliver(ev)
+			}
+		})
+	}
+}
+
+// deliver delivers ev to the subscribers of topic in the current goroutine.
+func (b *Bus[K, T]) deliver(topic K, ev T) {
+	b.mu.Lock()
+	reg, ok := b.topics[topic]
+	b.mu.Unlock()
+
+	if ok {
+		deliverAll(reg, ev)
+	}
+}
+
+func deliverAll[T any](reg *registry.SyncRegistry, ev T) {
+	reg.Each(func(v, _ interface{}) { v.(*subscriber[T]).deliver(ev) })
+}
@@ -0,0 +1,145 @@
+package eventbus
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+)
+
+func TestBus(t *testing.T) {
+	bus := New[string, int](Opts{Delivery: Direct})
+
+	var got []int
+	sub := bus.Subscribe(context.Background(), "a", func(v int) { got = append(got, v) })
+
+	var once []int
+	bus.SubscribeOnce(context.Background(), "a", func(v int) { once = append(once, v) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var cancelled []int
+	bus.Subscribe(ctx, "a", func(v int) { cancelled = append(cancelled, v) })
+
+	bus.Publish("b", 0)
+	bus.Publish("a", 1)
+	bus.Publish("a", 2)
+
+	cancel()
+	for bus.subscriberCount("a") > 1 {
+		// context.AfterFunc unsubscribes in its own goroutine.
+		runtime.Gosched()
+	}
+
+	bus.Publish("a", 3)
+	sub.Unsubscribe()
+	bus.Publish("a", 4)
+
+	if !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Errorf("unexpected events for subscriber: %v", got)
+	}
+	if !reflect.DeepEqual(once, []int{1}) {
+		t.Errorf("unexpected events for one-shot subscriber: %v", once)
+	}
+	if !reflect.DeepEqual(cancelled, []int{1, 2}) {
+		t.Errorf("unexpected events for cancelled subscriber: %v", cancelled)
+	}
+	if bus.HasSubscribers("a") {
+		t.Errorf("topic still has subscribers after unsubscribing")
+	}
+}
+
+func TestBusCancelledContext(t *testing.T) {
+	bus := New[string, int](Opts{Delivery: Direct})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var got []int
+	sub := bus.Subscribe(ctx, "a", func(v int) { got = append(got, v) })
+
+	for !sub.Done() || bus.HasSubscribers("a") {
+		// context.AfterFunc unsubscribes in its own goroutine.
+		runtime.Gosched()
+	}
+
+	bus.Publish("a", 1)
+	sub.Unsubscribe()
+
+	if len(got) != 0 {
+		t.Errorf("unexpected events for subscriber with a cancelled context: %v", got)
+	}
+}
+
+func TestBusOnceContext(t *testing.T) {
+	bus := New[string, int](Opts{Delivery: Direct})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var got []int
+	sub := bus.SubscribeOnce(ctx, "a", func(v int) { got = append(got, v) })
+
+	bus.Publish("a", 1)
+	bus.Publish("a", 2)
+
+	if !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("unexpected events for one-shot subscriber: %v", got)
+	}
+	if !sub.Done() {
+		t.Errorf("one-shot subscription is not done after an event")
+	}
+
+	bus.mu.Lock()
+	stopped := !sub.stop()
+	bus.mu.Unlock()
+
+	if !stopped {
+		t.Errorf("one-shot subscription did not stop its context callback")
+	}
+}
+
+func TestBusMainLoop(t *testing.T) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	bus := New[string, int](Opts{Delivery: MainLoop})
+
+	var got []int
+	bus.Subscribe(context.Background(), "a", func(v int) { got = append(got, v) })
+
+	published := make(chan struct{})
+	go func() {
+		bus.Publish("a", 1)
+		bus.Publish("a", 2)
+		close(published)
+	}()
+	<-published
+
+	// Nothing is delivered until the main loop runs.
+	if len(got) != 0 {
+		t.Fatalf("events delivered outside of the main loop: %v", got)
+	}
+
+	mainCtx := glib.MainContextDefault()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(got) < 2 && time.Now().Before(deadline) {
+		mainCtx.Iteration(false)
+	}
+
+	if !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("unexpected events delivered on the main loop: %v", got)
+	}
+}
+
+func (b *Bus[K, T]) subscriberCount(topic K) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if reg, ok := b.topics[topic]; ok {
+		return reg.Len()
+	}
+	return 0
+}
@@ -0,0 +1,151 @@
+package registry
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	debug      atomic.Bool
+	debugHolds atomic.Int32
+)
+
+// SetDebug sets whether registries should record the call site of each Add.
+// It is disabled by default, since recording a call site requires a stack
+// walk. Only values added while debugging is enabled have their call sites
+// recorded.
+func SetDebug(enable bool) { debug.Store(enable) }
+
+// HoldDebug enables debugging until the returned function is called, as if
+// SetDebug(true) were called. Debugging stays enabled while any hold is not
+// yet released, and releasing a hold never overrides SetDebug, so holds may
+// be used by tests that run in parallel. Calling the returned function more
+// than once is a no-op.
+func HoldDebug() (release func()) {
+	debugHolds.Add(1)
+
+	var once sync.Once
+	return func() { once.Do(func() { debugHolds.Add(-1) }) }
+}
+
+func isDebug() bool {
+	return debug.Load() || debugHolds.Load() > 0
+}
+
+// CallSite is the location in the source code that added a value into a
+// registry. The zero value is an unknown call site.
+type CallSite struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// String formats the call site as "file:line (function)".
+func (c CallSite) String() string {
+	if c == (CallSite{}) {
+		return "unknown call site"
+	}
+	return fmt.Sprintf("%s:%d (%s)", c.File, c.Line, c.Function)
+}
+
+// CallSite returns the call site that added the value. It returns the zero
+// CallSite if debugging was not enabled when the value was added.
+func (v *Value) CallSite() CallSite {
+	if v.site != nil {
+		return *v.site
+	}
+	return CallSite{}
+}
+
+var pkgPrefix = reflect.TypeOf(Value{}).PkgPath() + "."
+
+// isRegistryMethod returns true if fn is the name of a method of Registry or
+// SyncRegistry.
+func isRegistryMethod(fn string) bool {
+	if !strings.HasPrefix(fn, pkgPrefix) {
+		return false
+	}
+	fn = strings.TrimPrefix(fn, pkgPrefix)
+	return strings.HasPrefix(fn, "(*Registry).") || strings.HasPrefix(fn, "(*SyncRegistry).")
+}
+
+// recordCallSite returns the first caller outside of the registry's methods if
+// debugging is enabled.
+func recordCallSite() *CallSite {
+	if !isDebug() {
+		return nil
+	}
+
+	var pcs [16]uintptr
+	n := runtime.Callers(2, pcs[:])
+
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !isRegistryMethod(frame.Function) {
+			return &CallSite{
+				Function: frame.Function,
+				File:     frame.File,
+				Line:     frame.Line,
+			}
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// Group is a group of live values that were added at the same call site.
+type Group struct {
+	CallSite CallSite
+	Values   []*Value
+}
+
+// groupByCallSite groups the live values in values by their call sites. The
+// largest groups come first.
+func groupByCallSite(values []*Value) []Group {
+	indices := make(map[CallSite]int)
+	var groups []Group
+
+	for _, v := range values {
+		if v.deleted.Load() {
+			continue
+		}
+
+		site := v.CallSite()
+
+		i, ok := indices[site]
+		if !ok {
+			i = len(groups)
+			indices[site] = i
+			groups = append(groups, Group{CallSite: site})
+		}
+
+		groups[i].Values = append(groups[i].Values, v)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].Values) > len(groups[j].Values)
+	})
+
+	return groups
+}
+
+// ByCallSite returns all live values in the Registry grouped by the call site
+// that added them. Call sites are only known for values added while debugging
+// is enabled; see SetDebug.
+func (r *Registry) ByCallSite() []Group {
+	return groupByCallSite(r.values)
+}
+
+// ByCallSite returns all live values in the SyncRegistry grouped by the call
+// site that added them. Call sites are only known for values added while
+// debugging is enabled; see SetDebug.
+func (r *SyncRegistry) ByCallSite() []Group {
+	return groupByCallSite(r.load())
+}
@@ -0,0 +1,74 @@
+package registry
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestByCallSite(t *testing.T) {
+	SetDebug(true)
+	defer SetDebug(false)
+
+	r := New(0)
+	for i := 0; i < 3; i++ {
+		r.Add(i, nil) // leaks 3 values
+	}
+	r.AddPriority("b", nil, 1).Delete()
+	addHelper(&r)
+
+	SetDebug(false)
+	r.Add("unknown", nil)
+
+	groups := r.ByCallSite()
+	if len(groups) != 3 {
+		t.Fatalf("expected 3 groups, got %d: %v", len(groups), groups)
+	}
+
+	if n := len(groups[0].Values); n != 3 {
+		t.Errorf("expected 3 values in the largest group, got %d", n)
+	}
+
+	site := groups[0].CallSite
+	if !strings.HasSuffix(site.File, "debug_test.go") || site.Function != pkgPrefix+"TestByCallSite" {
+		t.Errorf("unexpected call site %s", site)
+	}
+
+	if site := groups[1].CallSite; site.Function != pkgPrefix+"addHelper" {
+		t.Errorf("unexpected call site %s", site)
+	}
+
+	if site := groups[2].CallSite; site != (CallSite{}) {
+		t.Errorf("expected unknown call site, got %s", site)
+	}
+}
+
+func addHelper(r *Registry) {
+	r.Add("helper", nil)
+}
+
+func TestHoldDebug(t *testing.T) {
+	SetDebug(false)
+
+	release1 := HoldDebug()
+	release2 := HoldDebug()
+
+	r := New(0)
+	release1()
+	release1() // no-op
+	if r.Add("held", nil).CallSite() == (CallSite{}) {
+		t.Error("call site not recorded while a hold is active")
+	}
+
+	release2()
+	if site := r.Add("released", nil).CallSite(); site != (CallSite{}) {
+		t.Errorf("call site recorded after all holds are released: %s", site)
+	}
+
+	SetDebug(true)
+	defer SetDebug(false)
+
+	HoldDebug()()
+	if r.Add("debug", nil).CallSite() == (CallSite{}) {
+		t.Error("releasing a hold overrides SetDebug")
+	}
+}
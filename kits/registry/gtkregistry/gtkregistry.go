@@ -0,0 +1,72 @@
+// Package gtkregistry ties registry values to the lifetime of GTK widgets, so
+// that values are deleted once their widgets are gone instead of leaking.
+package gtkregistry
+
+import (
+	"github.com/diamondburned/chatkit/kits/registry"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
+)
+
+// Lifetime determines when a value bound to a widget is deleted.
+type Lifetime uint8
+
+const (
+	// UntilDestroy deletes the value when the widget is destroyed.
+	UntilDestroy Lifetime = iota
+	// UntilUnmap deletes the value when the widget is unmapped or destroyed,
+	// whichever comes first. Mapping the widget again does not restore the
+	// value.
+	UntilUnmap
+)
+
+// Adder is a registry that values can be added to. It is implemented by
+// *registry.Registry and *registry.SyncRegistry.
+type Adder interface {
+	Add(v, meta interface{}) *registry.Value
+}
+
+var (
+	_ Adder = (*registry.Registry)(nil)
+	_ Adder = (*registry.SyncRegistry)(nil)
+)
+
+// Bind deletes v when w reaches the end of the given lifetime. The signal
+// handlers that Bind connects do not reference w, but v.V itself must not hold
+// a strong reference to w either, otherwise w is kept alive by the registry.
+// Use AddFunc for that.
+func Bind(w gtk.Widgetter, v *registry.Value, lifetime Lifetime) {
+	base := gtk.BaseWidget(w)
+	base.ConnectDestroy(v.Delete)
+
+	if lifetime == UntilUnmap {
+		base.ConnectUnmap(v.Delete)
+	}
+}
+
+// AddFunc adds a func(T) callback into r that calls f with w. The callback
+// only holds a weak reference to w, so the registry never keeps w alive. The
+// value is deleted when w reaches the end of the given lifetime, or when the
+// callback is called after w has already been finalized.
+//
+// Publishers should call the value as a func(T):
+//
+//	r.Each(func(v, meta interface{}) { v.(func(Event))(ev) })
+func AddFunc[W gtk.Widgetter, T any](r Adder, w W, meta interface{}, lifetime Lifetime, f func(W, T)) *registry.Value {
+	var v *registry.Value
+	var zero W
+
+	ref := coreglib.NewWeakRef(w)
+	v = r.Add(func(ev T) {
+		w := ref.Get()
+		if any(w) == any(zero) {
+			v.Delete()
+			return
+		}
+		f(w, ev)
+	}, meta)
+
+	Bind(w, v, lifetime)
+	return v
+}
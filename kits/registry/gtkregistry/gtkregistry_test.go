@@ -0,0 +1,96 @@
+package gtkregistry
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/chatkit/kits/registry"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
+)
+
+func initGTK(t *testing.T) {
+	t.Helper()
+	if !gtk.InitCheck() {
+		t.Skip("cannot initialize GTK")
+	}
+	runtime.LockOSThread()
+	t.Cleanup(runtime.UnlockOSThread)
+}
+
+func TestBindDestroy(t *testing.T) {
+	initGTK(t)
+
+	r := registry.New(0)
+	win := gtk.NewWindow()
+	Bind(win, r.Add("value", nil), UntilDestroy)
+
+	win.Present()
+	win.SetVisible(false)
+	if r.Len() != 1 {
+		t.Fatalf("value deleted before the widget is destroyed")
+	}
+
+	win.Destroy()
+	if !r.IsEmpty() {
+		t.Fatalf("value not deleted after the widget is destroyed")
+	}
+}
+
+func TestBindUnmap(t *testing.T) {
+	initGTK(t)
+
+	r := registry.New(0)
+	win := gtk.NewWindow()
+	defer win.Destroy()
+
+	Bind(win, r.Add("value", nil), UntilUnmap)
+
+	win.Present()
+	if r.Len() != 1 {
+		t.Fatalf("value deleted before the widget is unmapped")
+	}
+
+	win.SetVisible(false)
+	if !r.IsEmpty() {
+		t.Fatalf("value not deleted after the widget is unmapped")
+	}
+}
+
+func TestAddFuncWeak(t *testing.T) {
+	initGTK(t)
+
+	r := registry.New(0)
+
+	var called bool
+	ref := func() *coreglib.WeakRef[*gtk.Label] {
+		label := gtk.NewLabel("")
+		AddFunc(&r, label, nil, UntilDestroy, func(*gtk.Label, int) { called = true })
+		return coreglib.NewWeakRef(label)
+	}()
+
+	// The label is only referenced by the registry's callback now, which
+	// must not keep it alive.
+	mainCtx := glib.MainContextDefault()
+	deadline := time.Now().Add(5 * time.Second)
+	for ref.Get() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("widget is kept alive by the registry")
+		}
+		runtime.GC()
+		for mainCtx.Iteration(false) {
+		}
+	}
+
+	r.Each(func(v, _ interface{}) { v.(func(int))(1) })
+
+	if called {
+		t.Error("callback called after the widget is finalized")
+	}
+	if !r.IsEmpty() {
+		t.Error("value not deleted after the widget is finalized")
+	}
+}
@@ -1,60 +1,153 @@
 // Package registry implements a registry of callbacks keyed by any value. It is
 // useful for applications to implement callback publishers conveniently.
+//
+// Values are iterated in order of their priority, highest first. Values of the
+// same priority are iterated in the order they were added.
 package registry
 
-import "sync"
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
 
 // Value is the boxed type.
 type Value struct {
 	V interface{}
 	_ [0]sync.Mutex
-	r *Registry
+	r owner
+
+	meta    interface{}
+	prio    int
+	site    *CallSite
+	deleted atomic.Bool
+}
+
+// owner is the registry that a Value belongs to.
+type owner interface {
+	delete(*Value)
 }
 
 // Delete deletes the box itself from the containing map. The value is
 // invalidated after the call finishes.
 func (v *Value) Delete() {
-	delete(v.r.m, v)
-	v.V = nil
+	v.r.delete(v)
+}
+
+// Priority returns the priority that the value was added with.
+func (v *Value) Priority() int { return v.prio }
+
+// insertIndex returns the index in values, which must be sorted by descending
+// priority, that a new value of the given priority should be inserted at.
+func insertIndex(values []*Value, prio int) int {
+	return sort.Search(len(values), func(i int) bool { return values[i].prio < prio })
 }
 
 // gc thrashing, the game
 
-// M is the map type.
+// Registry is the callback registry.
 type Registry struct {
-	m map[*Value]interface{}
+	values  []*Value // sorted by priority, may contain deleted values
+	live    int
+	walking int
+	dirty   bool
 }
 
-// New creates a new M instance.
+// New creates a new Registry instance.
 func New(cap int) Registry {
 	return Registry{
-		m: make(map[*Value]interface{}, cap),
+		values: make([]*Value, 0, cap),
 	}
 }
 
 // IsEmpty returns true if the Registry is empty.
-func (r *Registry) IsEmpty() bool { return len(r.m) == 0 }
+func (r *Registry) IsEmpty() bool { return r.live == 0 }
+
+// Len returns the number of values in the Registry.
+func (r *Registry) Len() int { return r.live }
 
-// Each iterates over the map.
+// Each iterates over the registry.
 func (r *Registry) Each(f func(interface{}, interface{})) {
 	r.EachValue(func(v *Value, meta interface{}) { f(v.V, meta) })
 }
 
-// EachValue iterates over the map and gives the raw Value.
+// EachValue iterates over the registry and gives the raw Value. Values deleted
+// during the iteration are skipped, and values added during the iteration are
+// not visited until the next iteration.
 func (r *Registry) EachValue(f func(*Value, interface{})) {
-	for v, metadata := range r.m {
-		f(v, metadata)
+	r.walking++
+	defer func() {
+		r.walking--
+		if r.walking == 0 && r.dirty {
+			r.compact()
+		}
+	}()
+
+	for _, v := range r.values {
+		if !v.deleted.Load() {
+			f(v, v.meta)
+		}
 	}
 }
 
 // Add adds the given interface and returns a new and unique box that identifies
 // it.
 func (r *Registry) Add(v, meta interface{}) *Value {
-	if r.m == nil {
-		r.m = make(map[*Value]interface{})
+	return r.AddPriority(v, meta, 0)
+}
+
+// AddPriority is like Add, except the value is given a priority. Values with a
+// higher priority are iterated before those with a lower priority.
+func (r *Registry) AddPriority(v, meta interface{}, prio int) *Value {
+	b := &Value{V: v, r: r, meta: meta, prio: prio, site: recordCallSite()}
+	i := insertIndex(r.values, prio)
+
+	if r.walking > 0 {
+		// Don't touch the backing array that is being iterated over.
+		values := make([]*Value, 0, len(r.values)+1)
+		values = append(values, r.values[:i]...)
+		values = append(values, b)
+		values = append(values, r.values[i:]...)
+		r.values = values
+	} else {
+		r.values = append(r.values, nil)
+		copy(r.values[i+1:], r.values[i:])
+		r.values[i] = b
 	}
 
-	b := &Value{V: v, r: r}
-	r.m[b] = meta
+	r.live++
 	return b
 }
+
+func (r *Registry) delete(v *Value) {
+	if v.deleted.Swap(true) {
+		return
+	}
+
+	v.V = nil
+	v.meta = nil
+	r.live--
+
+	// Leave the deleted value in place and only compact once deleted values
+	// outnumber the live ones, so deleting stays amortized O(1). Compacting is
+	// deferred until all iterations are done.
+	r.dirty = true
+	if r.walking == 0 && r.live < len(r.values)-r.live {
+		r.compact()
+	}
+}
+
+func (r *Registry) compact() {
+	values := r.values[:0]
+	for _, v := range r.values {
+		if !v.deleted.Load() {
+			values = append(values, v)
+		}
+	}
+	// Clear the tail so deleted values can be collected.
+	for i := len(values); i < len(r.values); i++ {
+		r.values[i] = nil
+	}
+	r.values = values
+	r.dirty = false
+}
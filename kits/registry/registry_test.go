@@ -0,0 +1,102 @@
+package registry
+
+import (
+	"reflect"
+	"testing"
+)
+
+type orderedRegistry interface {
+	Each(func(interface{}, interface{}))
+	AddPriority(v, meta interface{}, prio int) *Value
+	Add(v, meta interface{}) *Value
+	Len() int
+}
+
+func TestRegistryOrder(t *testing.T) {
+	r := New(0)
+	testRegistryOrder(t, &r)
+}
+
+func TestSyncRegistryOrder(t *testing.T) {
+	testRegistryOrder(t, NewSync(0))
+}
+
+func testRegistryOrder(t *testing.T, r orderedRegistry) {
+	r.Add("notify 1", nil)
+	r.AddPriority("log", nil, -10)
+	r.Add("notify 2", nil)
+	r.AddPriority("mark as read", nil, 10)
+	r.Add("notify 3", nil)
+	r.AddPriority("mark as unread", nil, 10)
+
+	expect := []interface{}{
+		"mark as read",
+		"mark as unread",
+		"notify 1",
+		"notify 2",
+		"notify 3",
+		"log",
+	}
+
+	for i := 0; i < 10; i++ {
+		var got []interface{}
+		r.Each(func(v, _ interface{}) { got = append(got, v) })
+
+		if !reflect.DeepEqual(got, expect) {
+			t.Fatalf("unexpected order:\nexpected %q\ngot      %q", expect, got)
+		}
+	}
+}
+
+func TestRegistryDeleteInEach(t *testing.T) {
+	r := New(0)
+	a := r.Add("a", nil)
+	b := r.Add("b", nil)
+	r.Add("c", nil)
+
+	var seen []interface{}
+	r.EachValue(func(v *Value, _ interface{}) {
+		seen = append(seen, v.V)
+		if v == a {
+			b.Delete()
+			r.AddPriority("d", nil, 1)
+		}
+		v.Delete()
+	})
+
+	if !reflect.DeepEqual(seen, []interface{}{"a", "c"}) {
+		t.Fatalf("unexpected values seen: %q", seen)
+	}
+
+	if r.Len() != 1 || len(r.values) != 1 {
+		t.Fatalf("expected 1 value left, got %d (%d stored)", r.Len(), len(r.values))
+	}
+
+	if b.V != nil {
+		t.Fatalf("deleted value is not invalidated: %v", b.V)
+	}
+}
+
+func TestRegistryDeleteCompacts(t *testing.T) {
+	r := New(0)
+
+	values := make([]*Value, 1000)
+	for i := range values {
+		values[i] = r.Add(i, nil)
+	}
+
+	for i, v := range values[:900] {
+		v.Delete()
+
+		if len(r.values) > 2*r.Len()+1 {
+			t.Fatalf("after %d deletes: %d values stored for %d live", i+1, len(r.values), r.Len())
+		}
+	}
+
+	var got []interface{}
+	r.Each(func(v, _ interface{}) { got = append(got, v) })
+
+	if len(got) != 100 || got[0] != 900 || got[99] != 999 {
+		t.Fatalf("unexpected values left: %v", got)
+	}
+}
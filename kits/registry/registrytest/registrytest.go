@@ -0,0 +1,58 @@
+// Package registrytest provides test helpers for catching leaked registry
+// values.
+package registrytest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/diamondburned/chatkit/kits/registry"
+)
+
+// Introspector is a registry that can be introspected. It is implemented by
+// *registry.Registry and *registry.SyncRegistry.
+type Introspector interface {
+	ByCallSite() []registry.Group
+}
+
+var (
+	_ Introspector = (*registry.Registry)(nil)
+	_ Introspector = (*registry.SyncRegistry)(nil)
+)
+
+// AssertEmpty fails the test if r still has any live value. The failure lists
+// the leaked values grouped by where they were added.
+func AssertEmpty(t testing.TB, r Introspector) {
+	t.Helper()
+
+	groups := r.ByCallSite()
+	if len(groups) == 0 {
+		return
+	}
+
+	var total int
+	var msg strings.Builder
+	for _, group := range groups {
+		total += len(group.Values)
+		fmt.Fprintf(&msg, "\n\t%d value(s) added at %s", len(group.Values), group.CallSite)
+	}
+
+	t.Errorf("registry has %d leaked value(s):%s", total, msg.String())
+}
+
+// EmptyAtCleanup enables debugging for the duration of the test and asserts
+// that r is empty once the test and all its subtests complete. It should be
+// called before anything is added into r, so that call sites are recorded.
+// Debugging is enabled using registry.HoldDebug, so it is safe to use in
+// parallel tests and it doesn't override registry.SetDebug.
+func EmptyAtCleanup(t testing.TB, r Introspector) {
+	t.Helper()
+
+	release := registry.HoldDebug()
+	t.Cleanup(func() {
+		t.Helper()
+		release()
+		AssertEmpty(t, r)
+	})
+}
@@ -0,0 +1,101 @@
+package registry
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// SyncRegistry is a Registry that is safe for concurrent use by multiple
+// goroutines. The zero value is ready to use.
+//
+// Iterating is lock-free: each call to Each or EachValue walks over a snapshot
+// of the registry taken when the call starts. Values added during the
+// iteration are not visited until the next iteration, while values deleted
+// during the iteration, including from within the callback itself, are
+// skipped once Delete returns.
+//
+// Unlike Registry, deleting a value does not clear its V field, since other
+// goroutines may still be reading it.
+type SyncRegistry struct {
+	mu   sync.Mutex
+	snap atomic.Pointer[[]*Value] // sorted by priority
+}
+
+// NewSync creates a new SyncRegistry instance.
+func NewSync(cap int) *SyncRegistry {
+	r := &SyncRegistry{}
+	snap := make([]*Value, 0, cap)
+	r.snap.Store(&snap)
+	return r
+}
+
+func (r *SyncRegistry) load() []*Value {
+	if snap := r.snap.Load(); snap != nil {
+		return *snap
+	}
+	return nil
+}
+
+// IsEmpty returns true if the SyncRegistry is empty.
+func (r *SyncRegistry) IsEmpty() bool { return len(r.load()) == 0 }
+
+// Len returns the number of values in the SyncRegistry.
+func (r *SyncRegistry) Len() int { return len(r.load()) }
+
+// Each iterates over the registry.
+func (r *SyncRegistry) Each(f func(interface{}, interface{})) {
+	r.EachValue(func(v *Value, meta interface{}) { f(v.V, meta) })
+}
+
+// EachValue iterates over the registry and gives the raw Value.
+func (r *SyncRegistry) EachValue(f func(*Value, interface{})) {
+	for _, v := range r.load() {
+		if !v.deleted.Load() {
+			f(v, v.meta)
+		}
+	}
+}
+
+// Add adds the given interface and returns a new and unique box that identifies
+// it.
+func (r *SyncRegistry) Add(v, meta interface{}) *Value {
+	return r.AddPriority(v, meta, 0)
+}
+
+// AddPriority is like Add, except the value is given a priority. Values with a
+// higher priority are iterated before those with a lower priority.
+func (r *SyncRegistry) AddPriority(v, meta interface{}, prio int) *Value {
+	b := &Value{V: v, r: r, meta: meta, prio: prio, site: recordCallSite()}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old := r.load()
+	i := insertIndex(old, prio)
+
+	snap := make([]*Value, 0, len(old)+1)
+	snap = append(snap, old[:i]...)
+	snap = append(snap, b)
+	snap = append(snap, old[i:]...)
+	r.snap.Store(&snap)
+
+	return b
+}
+
+func (r *SyncRegistry) delete(v *Value) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if v.deleted.Swap(true) {
+		return
+	}
+
+	old := r.load()
+	snap := make([]*Value, 0, len(old))
+	for _, value := range old {
+		if value != v {
+			snap = append(snap, value)
+		}
+	}
+	r.snap.Store(&snap)
+}
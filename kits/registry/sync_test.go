@@ -0,0 +1,102 @@
+package registry
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSyncRegistryDeleteInEach(t *testing.T) {
+	r := NewSync(0)
+	a := r.Add("a", nil)
+	b := r.Add("b", nil)
+	r.Add("c", nil)
+
+	var seen []interface{}
+	r.EachValue(func(v *Value, _ interface{}) {
+		seen = append(seen, v.V)
+		switch v {
+		case a:
+			// Deleting a value that's yet to be visited must skip it.
+			b.Delete()
+			// Added values are not visited until the next iteration.
+			r.Add("d", nil)
+		}
+		// Deleting the current value must not disturb the iteration.
+		v.Delete()
+	})
+
+	if len(seen) != 2 || seen[0] != "a" || seen[1] != "c" {
+		t.Fatalf("unexpected values seen: %v", seen)
+	}
+
+	if r.Len() != 1 {
+		t.Fatalf("expected 1 value left, got %d", r.Len())
+	}
+
+	r.Each(func(v, _ interface{}) {
+		if v != "d" {
+			t.Fatalf("unexpected value %v", v)
+		}
+	})
+}
+
+func TestSyncRegistryDeleteTwice(t *testing.T) {
+	var r SyncRegistry
+	v := r.Add(1, nil)
+	r.Add(2, nil)
+
+	v.Delete()
+	v.Delete()
+
+	if r.Len() != 1 {
+		t.Fatalf("expected 1 value left, got %d", r.Len())
+	}
+}
+
+func TestSyncRegistryConcurrent(t *testing.T) {
+	const (
+		workers = 8
+		rounds  = 200
+	)
+
+	r := NewSync(0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+
+		// Subscribers come and go.
+		go func() {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				v := r.Add(j, "meta")
+				if j%2 == 0 {
+					v.Delete()
+				}
+			}
+		}()
+
+		// Publishers iterate and occasionally remove values from inside the
+		// callback.
+		go func() {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				r.EachValue(func(v *Value, meta interface{}) {
+					if meta != "meta" {
+						t.Errorf("unexpected meta %v", meta)
+					}
+					if v.V.(int)%3 == 0 {
+						v.Delete()
+					}
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	r.Each(func(v, _ interface{}) {
+		if n := v.(int); n%2 == 0 {
+			t.Errorf("deleted value %d is still in the registry", n)
+		}
+	})
+}
@@ -9,7 +9,8 @@ import (
 
 // ListIndex is a list item index.
 type ListIndex struct {
-	// Level is the depth of the list the item is in.
+	// Level is the depth of the list the item is in, starting from 1 for
+	// top-level lists.
 	Level int
 	// Index is the index of the list item.
 	Index int
@@ -17,14 +18,41 @@ type ListIndex struct {
 	Unordered bool
 }
 
-// ListItemBlock is a text block that contains a list item.
+// Bullet returns the text of the bullet or number in front of the list item.
+func (ix ListIndex) Bullet() string {
+	if !ix.Unordered {
+		return strconv.Itoa(ix.Index) + "."
+	}
+
+	// Alternate the bullets for nested lists.
+	switch (ix.Level - 1) % 3 {
+	case 1:
+		return "◦"
+	case 2:
+		return "▪"
+	default:
+		return "•"
+	}
+}
+
+// ListItemBlock is a widget block that contains a list item. It boxes another
+// list of widget blocks, so list items can contain nested lists, codeblocks and
+// quotes. To use it, create a new ListItemBlock using NewListItemBlock with the
+// current ContainerState, then use ListItemBlock.State to walk further.
 type ListItemBlock struct {
 	*gtk.Box
 	ListIndex ListIndex
 
-	text *TextBlock
+	state  *ContainerState
+	bullet *gtk.Label
+	check  *gtk.CheckButton
 }
 
+var (
+	_ WidgetBlock          = (*ListItemBlock)(nil)
+	_ ContainerWidgetBlock = (*ListItemBlock)(nil)
+)
+
 var bulletCSS = cssutil.Applier("md-listitem-bullet", `
 	.md-listitem-bullet {
 		margin-right: 0.5em;
@@ -33,39 +61,70 @@ var bulletCSS = cssutil.Applier("md-listitem-bullet", `
 	.md-listitem-unordered {
 		font-size: 1.2em;
 	}
+	.md-listitem-checkbox {
+		margin-right: 0.25em;
+	}
 `)
 
 // NewListItemBlock creates a new ListItemBlock.
 func NewListItemBlock(state *ContainerState, listIndex ListIndex) *ListItemBlock {
-	text := NewTextBlock(state)
-
-	bullet := gtk.NewLabel("")
+	bullet := gtk.NewLabel(listIndex.Bullet())
 	bullet.SetHExpand(false)
 	bullet.SetVExpand(false)
 	bullet.SetVAlign(gtk.AlignStart)
-	bullet.SetMarginStart(listIndex.Level*6 - 6)
 	bulletCSS(bullet)
 
 	if listIndex.Unordered {
-		bullet.SetText("•")
 		bullet.AddCSSClass("md-listitem-unordered")
 	} else {
-		bullet.SetText(strconv.Itoa(listIndex.Index) + ".")
 		bullet.AddCSSClass("md-listitem-ordered")
 	}
 
+	content := gtk.NewBox(gtk.OrientationVertical, 0)
+	content.AddCSSClass("md-listitem-content")
+	content.SetHExpand(true)
+
 	box := gtk.NewBox(gtk.OrientationHorizontal, 0)
 	box.AddCSSClass("md-listitem")
+	box.AddCSSClass("md-listitem-level-" + strconv.Itoa(listIndex.Level))
 	box.Append(bullet)
-	box.Append(text.TextView)
+	box.Append(content)
 
 	return &ListItemBlock{
-		Box:  box,
-		text: text,
+		Box:       box,
+		ListIndex: listIndex,
+		state:     state.WithParent(content),
+		bullet:    bullet,
+	}
+}
+
+// State returns the ContainerState of the list item's content. It implements
+// ContainerWidgetBlock.
+func (b *ListItemBlock) State() *ContainerState {
+	return b.state
+}
+
+// SetTask turns the list item into a task list item by showing a checkbox
+// before its content. The checkbox replaces the bullet of unordered lists. It
+// reflects the given state and cannot be toggled by the user.
+func (b *ListItemBlock) SetTask(checked bool) {
+	if b.check == nil {
+		b.check = gtk.NewCheckButton()
+		b.check.AddCSSClass("md-listitem-checkbox")
+		b.check.SetVAlign(gtk.AlignStart)
+		b.check.SetCanTarget(false)
+		b.check.SetCanFocus(false)
+		b.Box.InsertChildAfter(b.check, b.bullet)
+
+		if b.ListIndex.Unordered {
+			b.bullet.SetVisible(false)
+		}
 	}
+
+	b.check.SetActive(checked)
 }
 
-// TextBlock returns the underlying TextBlock.
-func (b *ListItemBlock) TextBlock() *TextBlock {
-	return b.text
+// IsTask returns true if the list item is a task list item.
+func (b *ListItemBlock) IsTask() bool {
+	return b.check != nil
 }
@@ -0,0 +1,107 @@
+package block
+
+import (
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+)
+
+// TableBlock is a widget block that lays out a table in a grid. The table
+// scrolls horizontally if it is too wide. To use it, create a new TableBlock
+// using NewTableBlock, then add rows and cells in order using AddRow and
+// AddCell.
+type TableBlock struct {
+	*gtk.ScrolledWindow
+	Grid *gtk.Grid
+
+	state  *ContainerState
+	cells  []*ContainerState
+	row    int
+	column int
+	header bool
+}
+
+var _ WidgetBlock = (*TableBlock)(nil)
+
+var tableBlockCSS = cssutil.Applier("md-table", `
+	.md-table {
+		margin: 2px 0;
+	}
+	.md-table scrollbar {
+		background: none;
+		border:     none;
+	}
+	.md-table-cell {
+		padding: 2px 6px;
+		border: 1px solid alpha(@theme_fg_color, 0.15);
+	}
+	.md-table-header {
+		background-color: alpha(@theme_fg_color, 0.06);
+	}
+	.md-table-header textview {
+		font-weight: bold;
+	}
+`)
+
+// NewTableBlock creates a new TableBlock.
+func NewTableBlock(state *ContainerState) *TableBlock {
+	grid := gtk.NewGrid()
+	grid.AddCSSClass("md-table-grid")
+	grid.SetHAlign(gtk.AlignStart)
+
+	sw := gtk.NewScrolledWindow()
+	sw.SetPolicy(gtk.PolicyAutomatic, gtk.PolicyNever)
+	sw.SetPropagateNaturalHeight(true)
+	sw.SetPropagateNaturalWidth(true)
+	sw.SetHExpand(true)
+	sw.SetChild(grid)
+	tableBlockCSS(sw)
+
+	return &TableBlock{
+		ScrolledWindow: sw,
+		Grid:           grid,
+		state:          state,
+		row:            -1,
+	}
+}
+
+// AddRow starts a new row. If header is true, then the cells in the row are
+// styled as header cells.
+func (b *TableBlock) AddRow(header bool) {
+	b.row++
+	b.column = 0
+	b.header = header
+}
+
+// AddCell adds a new cell to the end of the current row and returns the
+// cell's ContainerState. The cell initially contains a single TextBlock whose
+// text is justified using the given justification. Use the returned state to
+// walk further.
+func (b *TableBlock) AddCell(justify gtk.Justification) *ContainerState {
+	if b.row < 0 {
+		b.AddRow(false)
+	}
+
+	box := gtk.NewBox(gtk.OrientationVertical, 0)
+	box.AddCSSClass("md-table-cell")
+	if b.header {
+		box.AddCSSClass("md-table-header")
+	}
+
+	cell := b.state.WithParent(box)
+
+	text := NewTextBlock(cell)
+	text.SetWrapMode(gtk.WrapNone)
+	text.SetJustification(justify)
+	cell.Append(text)
+
+	b.Grid.Attach(box, b.column, b.row, 1, 1)
+	b.cells = append(b.cells, cell)
+	b.column++
+
+	return cell
+}
+
+// Cells returns the ContainerStates of all cells in the table, row by row.
+func (b *TableBlock) Cells() []*ContainerState {
+	return b.cells
+}
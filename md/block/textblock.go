@@ -75,9 +75,23 @@ func (b *TextBlock) TextBlock() *TextBlock { return b }
 
 // ConnectLinkHandler connects the hyperlink handler into the TextBlock. Call
 // this method if the TextBlock has a link. Only the first call will bind the
-// handler.
+// handler. Link menu items in the viewer's context are added to the context
+// menu of links, and the viewer context's link guard checks links before
+// they're opened; see md.WithLinkMenuItems and md.WithLinkGuard.
 func (b *TextBlock) ConnectLinkHandler() {
-	md.BindLinkHandler(b.TextView, func(url string) { app.OpenURI(b.state.Context(), url) })
+	ctx := b.state.Context()
+	md.BindLinkHandlerWithOpts(b.TextView, md.LinkHandlerOpts{
+		OnURL:     func(url string) { app.OpenURI(ctx, url) },
+		MenuItems: md.LinkMenuItemsFromContext(ctx),
+		Guard:     md.LinkGuardFromContext(ctx),
+	})
+}
+
+// ConnectSpoilerHandler connects the spoiler handler into the TextBlock. Call
+// this method if the TextBlock has a spoiler. Only the first call will bind the
+// handler.
+func (b *TextBlock) ConnectSpoilerHandler() {
+	md.BindSpoilerHandler(b.TextView)
 }
 
 // TrailingNewLines counts the number of trailing new lines up to 2.
@@ -131,8 +145,7 @@ func (b *TextBlock) InsertNewLines(n int) {
 
 // ApplyLink applies tags denoting a hyperlink.
 func (b *TextBlock) ApplyLink(url string, start, end *gtk.TextIter) {
-	embedURL := md.URLTagName(start, end, url)
-	b.Buffer.ApplyTag(b.EmptyTag(embedURL), start, end)
+	md.AddLink(b.Buffer, start, end, url)
 	b.Buffer.ApplyTag(textutil.LinkTags().FromTable(b.state.TagTable(), "a"), start, end)
 	b.ConnectLinkHandler()
 }
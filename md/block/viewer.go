@@ -3,6 +3,7 @@ package block
 import (
 	"context"
 
+	"github.com/diamondburned/chatkit/md"
 	"github.com/diamondburned/gotk4/pkg/gio/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 )
@@ -51,7 +52,8 @@ func (v *Viewer) TagTable() *gtk.TextTagTable {
 
 // SetExtraMenu sets the given menu for all children widget nodes.
 func (v *Viewer) SetExtraMenu(model gio.MenuModeller) {
-	v.state.Walk(func(w WidgetBlock) bool {
+	var setExtraMenu func(w WidgetBlock) bool
+	setExtraMenu = func(w WidgetBlock) bool {
 		switch w := w.(type) {
 		case TextWidgetBlock:
 			w.TextBlock().SetExtraMenu(model)
@@ -59,7 +61,39 @@ func (v *Viewer) SetExtraMenu(model gio.MenuModeller) {
 			w.SetExtraMenu(model)
 		case *CodeBlock:
 			w.text.SetExtraMenu(model)
+		case *TableBlock:
+			for _, cell := range w.Cells() {
+				cell.Walk(setExtraMenu)
+			}
 		}
 		return false
-	})
+	}
+
+	v.state.Walk(setExtraMenu)
+}
+
+// EnlargeEmojis enlarges the emojis in the viewer if it only contains a single
+// text block of at most max emojis, like most chat clients do for messages
+// that are only emojis. It should be called after rendering. True is returned
+// if the emojis were enlarged.
+func (v *Viewer) EnlargeEmojis(max int) bool {
+	if v.state.list.Len() != 1 {
+		return false
+	}
+
+	text, ok := v.state.list.Front().Value.(*TextBlock)
+	if !ok {
+		return false
+	}
+
+	start, end := text.Buffer.Bounds()
+
+	n, ok := md.CountEmojis(text.Buffer.Slice(start, end, false))
+	if !ok || n == 0 || n > max {
+		return false
+	}
+
+	text.Buffer.ApplyTag(md.Tags.FromTable(v.table, "_emoji"), start, end)
+	text.AddCSSClass("md-jumbo-emoji")
+	return true
 }
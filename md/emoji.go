@@ -0,0 +1,339 @@
+package md
+
+import (
+	"bufio"
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	"golang.org/x/text/unicode/norm"
+	"libdb.so/go-emoji"
+	"libdb.so/go-emoji/data"
+)
+
+// EmojiShortcode is an emoji shortcode, such as "thumbs_up", along with the
+// emoji that it stands for.
+type EmojiShortcode struct {
+	// Shortcode is the shortcode without the surrounding colons.
+	Shortcode string
+	// Unicode is the emoji.
+	Unicode string
+}
+
+// EmojiAliases maps common shortcodes that are not derived from the Unicode
+// emoji names to their emojis. Shortcodes are otherwise derived from the
+// Unicode names, so "thumbs up" becomes "thumbs_up". It may be modified to add
+// more shortcodes, but not concurrently with parsing.
+var EmojiAliases = map[string]string{
+	"+1":               "👍",
+	"-1":               "👎",
+	"thumbsup":         "👍",
+	"thumbsdown":       "👎",
+	"smile":            "😄",
+	"smiley":           "😃",
+	"laughing":         "😆",
+	"sweat_smile":      "😅",
+	"joy":              "😂",
+	"wink":             "😉",
+	"blush":            "😊",
+	"heart_eyes":       "😍",
+	"thinking":         "🤔",
+	"cry":              "😢",
+	"sob":              "😭",
+	"heart":            "❤️",
+	"broken_heart":     "💔",
+	"tada":             "🎉",
+	"eyes":             "👀",
+	"fire":             "🔥",
+	"100":              "💯",
+	"ok_hand":          "👌",
+	"wave":             "👋",
+	"pray":             "🙏",
+	"clap":             "👏",
+	"muscle":           "💪",
+	"shrug":            "🤷",
+	"facepalm":         "🤦",
+	"skull":            "💀",
+	"sparkles":         "✨",
+	"star":             "⭐",
+	"rocket":           "🚀",
+	"white_check_mark": "✅",
+	"x":                "❌",
+	"warning":          "⚠️",
+}
+
+var emojiShortcodes struct {
+	once sync.Once
+	list []EmojiShortcode // sorted by shortcode
+	m    map[string]string
+}
+
+// loadEmojiShortcodes derives the shortcodes from the emoji names in the
+// Unicode emoji test data.
+func loadEmojiShortcodes() {
+	emojiShortcodes.m = make(map[string]string, 4096)
+
+	scanner := bufio.NewScanner(bytes.NewReader(emoji.Latest.FileBytes(data.Test_)))
+	for scanner.Scan() {
+		// Lines look like this:
+		// 1F44D ; fully-qualified # 👍 thumbs up
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		codepoints, rest, ok := strings.Cut(line, ";")
+		if !ok {
+			continue
+		}
+
+		status, comment, ok := strings.Cut(rest, "#")
+		if !ok || strings.TrimSpace(status) != "fully-qualified" {
+			continue
+		}
+
+		var unicode strings.Builder
+		for _, field := range strings.Fields(codepoints) {
+			r, err := strconv.ParseUint(field, 16, 32)
+			if err != nil {
+				continue
+			}
+			unicode.WriteRune(rune(r))
+		}
+
+		// Skip the emoji itself in the comment.
+		_, name, _ := strings.Cut(strings.TrimSpace(comment), " ")
+
+		shortcode := nameToShortcode(name)
+		if shortcode == "" {
+			continue
+		}
+
+		if _, ok := emojiShortcodes.m[shortcode]; !ok {
+			emojiShortcodes.m[shortcode] = unicode.String()
+			emojiShortcodes.list = append(emojiShortcodes.list, EmojiShortcode{
+				Shortcode: shortcode,
+				Unicode:   unicode.String(),
+			})
+		}
+	}
+
+	sort.Slice(emojiShortcodes.list, func(i, j int) bool {
+		return emojiShortcodes.list[i].Shortcode < emojiShortcodes.list[j].Shortcode
+	})
+}
+
+var shortcodeReplacer = strings.NewReplacer(
+	"#", "hash",
+	"*", "asterisk",
+	"'", "",
+	"’", "",
+)
+
+// nameToShortcode converts a Unicode emoji name to a shortcode. Diacritics
+// are removed, and everything that's not a letter or a digit is collapsed
+// into underscores, so "flag: Côte d’Ivoire" becomes "flag_cote_divoire".
+func nameToShortcode(name string) string {
+	name = shortcodeReplacer.Replace(norm.NFD.String(strings.ToLower(name)))
+
+	var b strings.Builder
+	b.Grow(len(name))
+
+	for _, r := range name {
+		switch {
+		case 'a' <= r && r <= 'z', '0' <= r && r <= '9':
+			b.WriteRune(r)
+		case unicode.Is(unicode.Mn, r):
+			// Drop combining diacritics.
+		default:
+			s := b.String()
+			if s != "" && !strings.HasSuffix(s, "_") {
+				b.WriteByte('_')
+			}
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "_")
+}
+
+// LookupEmoji returns the emoji for the given shortcode, which must not have
+// the surrounding colons.
+func LookupEmoji(shortcode string) (string, bool) {
+	if unicode, ok := EmojiAliases[shortcode]; ok {
+		return unicode, true
+	}
+
+	emojiShortcodes.once.Do(loadEmojiShortcodes)
+	unicode, ok := emojiShortcodes.m[shortcode]
+	return unicode, ok
+}
+
+// SearchEmojis searches for emojis whose shortcodes contain the given query.
+// Shortcodes that start with the query are returned first, and shorter
+// shortcodes are returned before longer ones. Each emoji is only returned
+// once under its best matching shortcode. At most max results are returned.
+func SearchEmojis(query string, max int) []EmojiShortcode {
+	if max <= 0 {
+		return nil
+	}
+
+	emojiShortcodes.once.Do(loadEmojiShortcodes)
+
+	query = strings.ToLower(query)
+
+	var prefixed, contained []EmojiShortcode
+	match := func(e EmojiShortcode) {
+		switch {
+		case strings.HasPrefix(e.Shortcode, query):
+			prefixed = append(prefixed, e)
+		case strings.Contains(e.Shortcode, query):
+			contained = append(contained, e)
+		}
+	}
+
+	for shortcode, unicode := range EmojiAliases {
+		match(EmojiShortcode{shortcode, unicode})
+	}
+	for _, e := range emojiShortcodes.list {
+		match(e)
+	}
+
+	for _, list := range [][]EmojiShortcode{prefixed, contained} {
+		sort.SliceStable(list, func(i, j int) bool {
+			if len(list[i].Shortcode) != len(list[j].Shortcode) {
+				return len(list[i].Shortcode) < len(list[j].Shortcode)
+			}
+			return list[i].Shortcode < list[j].Shortcode
+		})
+	}
+
+	// Aliases and derived shortcodes may stand for the same emoji, so only
+	// keep the best match of each emoji.
+	seen := make(map[string]struct{}, max)
+	results := make([]EmojiShortcode, 0, max)
+
+	for _, list := range [][]EmojiShortcode{prefixed, contained} {
+		for _, e := range list {
+			if len(results) == max {
+				return results
+			}
+			if _, ok := seen[e.Unicode]; ok {
+				continue
+			}
+			seen[e.Unicode] = struct{}{}
+			results = append(results, e)
+		}
+	}
+
+	return results
+}
+
+// KindEmoji is the NodeKind of the Emoji node.
+var KindEmoji = ast.NewNodeKind("Emoji")
+
+// Emoji is an inline node of an emoji that is written as a shortcode, such as
+// :thumbs_up:.
+type Emoji struct {
+	ast.BaseInline
+	// Segment is the segment of the shortcode including the colons.
+	Segment text.Segment
+	// Shortcode is the shortcode without the colons.
+	Shortcode string
+	// Unicode is the emoji that the shortcode stands for.
+	Unicode string
+}
+
+// Kind implements ast.Node.
+func (n *Emoji) Kind() ast.NodeKind { return KindEmoji }
+
+// Dump implements ast.Node.
+func (n *Emoji) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{
+		"Shortcode": n.Shortcode,
+		"Unicode":   n.Unicode,
+	}, nil)
+}
+
+type emojiParser struct{}
+
+// NewEmojiParser returns a new InlineParser that parses :shortcode:
+// expressions into Emoji nodes. Unknown shortcodes are left as text.
+func NewEmojiParser() parser.InlineParser {
+	return emojiParser{}
+}
+
+func (p emojiParser) Trigger() []byte {
+	return []byte{':'}
+}
+
+func (p emojiParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	// Don't match within words or numbers, such as in 12:30:00.
+	before := block.PrecendingCharacter()
+	if unicode.IsLetter(before) || unicode.IsDigit(before) {
+		return nil
+	}
+
+	line, segment := block.PeekLine()
+
+	end := 1
+	for end < len(line) && isShortcodeByte(line[end]) {
+		end++
+	}
+	if end == 1 || end >= len(line) || line[end] != ':' {
+		return nil
+	}
+
+	shortcode := string(line[1:end])
+
+	unicode, ok := LookupEmoji(shortcode)
+	if !ok {
+		return nil
+	}
+
+	block.Advance(end + 1)
+
+	return &Emoji{
+		Segment:   segment.WithStop(segment.Start + end + 1),
+		Shortcode: shortcode,
+		Unicode:   unicode,
+	}
+}
+
+func isShortcodeByte(b byte) bool {
+	switch {
+	case 'a' <= b && b <= 'z', '0' <= b && b <= '9':
+		return true
+	case b == '_', b == '+', b == '-':
+		return true
+	default:
+		return false
+	}
+}
+
+type emojiHTMLRenderer struct{}
+
+// NewEmojiHTMLRenderer returns a new NodeRenderer that renders Emoji nodes as
+// their emojis.
+func NewEmojiHTMLRenderer() renderer.NodeRenderer {
+	return emojiHTMLRenderer{}
+}
+
+// RegisterFuncs implements renderer.NodeRenderer.
+func (r emojiHTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindEmoji, r.renderEmoji)
+}
+
+func (r emojiHTMLRenderer) renderEmoji(w util.BufWriter, src []byte, n ast.Node, enter bool) (ast.WalkStatus, error) {
+	if enter {
+		w.Write(util.EscapeHTML([]byte(n.(*Emoji).Unicode)))
+	}
+	return ast.WalkSkipChildren, nil
+}
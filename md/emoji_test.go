@@ -0,0 +1,147 @@
+package md
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestConverterEmoji(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{
+			name: "derived",
+			in:   ":thumbs_up: :flag_japan: :keycap_hash:",
+			out:  "<p>👍 🇯🇵 #️⃣</p>\n",
+		},
+		{
+			name: "alias",
+			in:   ":+1::tada:",
+			out:  "<p>👍🎉</p>\n",
+		},
+		{
+			name: "unknown",
+			in:   ":not_an_emoji:",
+			out:  "<p>:not_an_emoji:</p>\n",
+		},
+		{
+			name: "within words",
+			in:   "12:30:00 a:smile:",
+			out:  "<p>12:30:00 a:smile:</p>\n",
+		},
+		{
+			name: "code span",
+			in:   "`:smile:`",
+			out:  "<p><code>:smile:</code></p>\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := Converter.Convert([]byte(test.in), &out); err != nil {
+				t.Fatal("cannot convert:", err)
+			}
+			if out.String() != test.out {
+				t.Errorf("unexpected output:\n got %q\nwant %q", out.String(), test.out)
+			}
+		})
+	}
+}
+
+func TestCountEmojis(t *testing.T) {
+	tests := []struct {
+		in string
+		n  int
+		ok bool
+	}{
+		{"👍", 1, true},
+		{"👍🏽 👍", 2, true},
+		{"👨‍👩‍👧‍👦", 1, true},
+		{"🇯🇵🇺🇸", 2, true},
+		{"#️⃣", 1, true},
+		{"🏴\U000e0067\U000e0062\U000e0073\U000e0063\U000e0074\U000e007f", 1, true},
+		{"❤️", 1, true},
+		{"🇯🇵🇺🇸🇬🇧", 3, true},
+		{"🇯🇵🇺", 2, true},
+		{"1️⃣2️⃣#️⃣", 3, true},
+		{"1⃣", 1, true},
+		{"12", 0, false},
+		{"a⃣", 0, false},
+		{"👩🏾‍💻", 1, true},
+		{"🧑🏽‍🤝‍🧑🏻", 1, true},
+		{"👨🏻‍❤️‍💋‍👨🏼", 1, true},
+		{"👍\u200d", 0, false},
+		{"👍\u0301", 0, false},
+		{"☺︎", 0, false},
+		{"©", 0, false},
+		{"™", 0, false},
+		{"↔", 0, false},
+		{"©️", 1, true},
+		{"☝🏽", 1, true},
+		{"🏃‍♂", 1, true},
+		{"1", 0, false},
+		{"hi 👍", 0, false},
+		{"", 0, true},
+	}
+
+	for _, test := range tests {
+		n, ok := CountEmojis(test.in)
+		if n != test.n || ok != test.ok {
+			t.Errorf("CountEmojis(%q) = (%d, %v), want (%d, %v)", test.in, n, ok, test.n, test.ok)
+		}
+	}
+}
+
+func TestIsUnicodeEmoji(t *testing.T) {
+	tests := []struct {
+		in string
+		ok bool
+	}{
+		{"👍 👍🏽", true},
+		{"™", false},
+		{"hi 👍", false},
+		{"", true},
+		{" ", true},
+	}
+
+	for _, test := range tests {
+		if ok := IsUnicodeEmoji(test.in); ok != test.ok {
+			t.Errorf("IsUnicodeEmoji(%q) = %v, want %v", test.in, ok, test.ok)
+		}
+	}
+}
+
+func TestSearchEmojis(t *testing.T) {
+	tests := []struct {
+		query string
+		first EmojiShortcode
+	}{
+		{"eyes", EmojiShortcode{"eyes", "👀"}},
+		{"fire", EmojiShortcode{"fire", "🔥"}},
+		{"thumbs", EmojiShortcode{"thumbsup", "👍"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			results := SearchEmojis(test.query, 50)
+			if len(results) == 0 || results[0] != test.first {
+				t.Fatalf("unexpected first result in %v, want %v", results, test.first)
+			}
+
+			seen := make(map[string]string, len(results))
+			for _, e := range results {
+				if shortcode, ok := seen[e.Unicode]; ok {
+					t.Errorf("%s is returned as both %q and %q", e.Unicode, shortcode, e.Shortcode)
+				}
+				seen[e.Unicode] = e.Shortcode
+			}
+		})
+	}
+
+	if results := SearchEmojis("e", 3); len(results) != 3 {
+		t.Errorf("got %d results, want 3", len(results))
+	}
+}
@@ -0,0 +1,147 @@
+package md
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/rivo/uniseg"
+	"libdb.so/go-emoji"
+	"libdb.so/go-emoji/data"
+)
+
+const (
+	zeroWidthJoiner   = '\u200d'
+	variationText     = '\ufe0e'
+	variationEmoji    = '\ufe0f'
+	combiningKeycap   = '\u20e3'
+	cancelTag         = '\U000e007f'
+	regionalIndicator = '\U0001f1e6'
+)
+
+// CountEmojis counts the emojis in s. s is split into grapheme clusters, and
+// each cluster must be exactly one emoji sequence, such as a ZWJ sequence, a
+// flag, a keycap or an emoji with a skin tone modifier. Whitespaces are
+// ignored. If s contains anything other than emojis and whitespaces, then false
+// is returned.
+func CountEmojis(s string) (int, bool) {
+	var n int
+	state := -1
+
+	for s != "" {
+		var cluster string
+		cluster, s, _, state = uniseg.FirstGraphemeClusterInString(s, state)
+
+		if strings.TrimFunc(cluster, unicode.IsSpace) == "" {
+			continue
+		}
+
+		runes := []rune(cluster)
+		if emojiSequenceLen(runes) != len(runes) {
+			return n, false
+		}
+
+		n++
+	}
+
+	return n, true
+}
+
+// emojiSequenceLen returns the number of runes of the emoji sequence at the
+// start of runes, or 0 if runes doesn't start with an emoji. Grapheme
+// segmentation alone can't tell whether a cluster is an emoji, so this checks
+// that the cluster is made of emoji elements joined by ZWJs.
+func emojiSequenceLen(runes []rune) int {
+	n := emojiElementLen(runes, false)
+	if n == 0 {
+		return 0
+	}
+
+	// ZWJ sequences join multiple emojis into one.
+	for n+1 < len(runes) && runes[n] == zeroWidthJoiner {
+		l := emojiElementLen(runes[n+1:], true)
+		if l == 0 {
+			break
+		}
+		n += 1 + l
+	}
+
+	return n
+}
+
+// emojiElementLen returns the number of runes of a single emoji at the start
+// of runes, including its modifiers, or 0 if there's none. joined is true if
+// the emoji follows a ZWJ, where emojis don't need to be presented as emojis
+// explicitly.
+func emojiElementLen(runes []rune, joined bool) int {
+	switch r := runes[0]; {
+	case isRegionalIndicator(r):
+		if len(runes) > 1 && isRegionalIndicator(runes[1]) {
+			return 2
+		}
+		return 1
+
+	case isKeycapBase(r):
+		n := 1
+		if n < len(runes) && runes[n] == variationEmoji {
+			n++
+		}
+		if n < len(runes) && runes[n] == combiningKeycap {
+			return n + 1
+		}
+		return 0
+
+	case !isEmojiBase(runes, joined):
+		return 0
+	}
+
+	n := 1
+	for n < len(runes) {
+		switch r := runes[n]; {
+		case r == variationText:
+			// The emoji is explicitly presented as text.
+			return 0
+		case r == variationEmoji, isSkinToneModifier(r), isTag(r):
+			n++
+		default:
+			return n
+		}
+	}
+
+	return n
+}
+
+// isEmojiBase returns true if runes starts with a character that is shown as
+// an emoji. Characters that are shown as text by default, such as "©", are
+// only emojis if they're followed by the emoji variation selector or a skin
+// tone modifier.
+func isEmojiBase(runes []rune, joined bool) bool {
+	r := runes[0]
+	// ASCII characters like digits also have the Emoji property, but they're
+	// only emojis as part of keycaps.
+	if r <= unicode.MaxASCII {
+		return false
+	}
+	if unicode.Is(emoji.Latest.RangeTable(data.Emoji_Presentation), r) {
+		return true
+	}
+	if !unicode.Is(emoji.Latest.RangeTable(data.Emoji), r) {
+		return false
+	}
+	return joined || (len(runes) > 1 && (runes[1] == variationEmoji || isSkinToneModifier(runes[1])))
+}
+
+func isKeycapBase(r rune) bool {
+	return r == '#' || r == '*' || ('0' <= r && r <= '9')
+}
+
+func isRegionalIndicator(r rune) bool {
+	return regionalIndicator <= r && r < regionalIndicator+26
+}
+
+func isSkinToneModifier(r rune) bool {
+	return '\U0001f3fb' <= r && r <= '\U0001f3ff'
+}
+
+func isTag(r rune) bool {
+	return '\U000e0020' <= r && r <= cancelTag
+}
@@ -0,0 +1,573 @@
+// Package htmlrender renders Matrix-flavored HTML, such as the formatted_body
+// of a message, into the same block widget tree that mdrender produces.
+package htmlrender
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/diamondburned/chatkit/md"
+	"github.com/diamondburned/chatkit/md/block"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/components/onlineimage"
+	"github.com/diamondburned/gotkit/gtkutil/imgutil"
+	"github.com/diamondburned/gotkit/gtkutil/textutil"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"libdb.so/ctxt"
+)
+
+// Sizes used for inline images.
+const (
+	// DefaultImageSize is the size of an inline image that doesn't specify
+	// its own width or height. Most of these images are custom emojis.
+	DefaultImageSize = 32
+	// MaxImageWidth and MaxImageHeight are the maximum sizes of an inline
+	// image.
+	MaxImageWidth  = 400
+	MaxImageHeight = 300
+)
+
+// OptionFunc is a function type for any options that modify Renderer's
+// internals.
+type OptionFunc func(r *Renderer)
+
+// WithImageProvider sets the provider used to fetch inline images. If no
+// provider is set, then images are rendered as their alt text.
+func WithImageProvider(provider imgutil.Provider) OptionFunc {
+	return func(r *Renderer) {
+		r.images = provider
+	}
+}
+
+// WithJumboEmojis makes HTMLViewer enlarge messages that only contain at most
+// max emojis. See block.Viewer.EnlargeEmojis.
+func WithJumboEmojis(max int) OptionFunc {
+	return func(r *Renderer) {
+		r.jumboEmojis = max
+	}
+}
+
+// Renderer is a rendering instance.
+type Renderer struct {
+	state       *block.ContainerState
+	images      imgutil.Provider
+	jumboEmojis int
+
+	// breaks is the number of line breaks that are pending until the next
+	// text insertion.
+	breaks int
+	// space is true if a space is pending until the next text insertion.
+	space bool
+}
+
+// NewRenderer creates a new renderer.
+func NewRenderer(state *block.ContainerState, opts ...OptionFunc) *Renderer {
+	r := Renderer{state: state}
+	for _, opt := range opts {
+		opt(&r)
+	}
+	return &r
+}
+
+// State returns the container state associated with the given context, or it
+// returns the default state.
+func (r *Renderer) State(ctx context.Context) *block.ContainerState {
+	state, ok := ctxt.From[*block.ContainerState](ctx)
+	if ok {
+		return state
+	}
+	return r.state
+}
+
+// Parse parses the given HTML fragment, returning the list of top-level nodes.
+func Parse(src string) ([]*html.Node, error) {
+	return html.ParseFragment(strings.NewReader(src), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+}
+
+// RenderString parses and renders the given HTML fragment.
+func (r *Renderer) RenderString(ctx context.Context, src string) error {
+	nodes, err := Parse(src)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range nodes {
+		r.Render(ctx, n)
+	}
+
+	return nil
+}
+
+// Render renders n and its children.
+func (r *Renderer) Render(ctx context.Context, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		r.renderText(ctx, n.Data)
+	case html.ElementNode:
+		r.renderElement(ctx, n)
+	case html.DocumentNode:
+		r.RenderChildren(ctx, n)
+	}
+}
+
+// RenderChildren renders all of n's children.
+func (r *Renderer) RenderChildren(ctx context.Context, n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.Render(ctx, c)
+	}
+}
+
+// RenderChildrenWithTag calls RenderChildren wrapped within the given tag.
+func (r *Renderer) RenderChildrenWithTag(ctx context.Context, n *html.Node, tag *gtk.TextTag) {
+	text := r.text(ctx)
+	text.TagBounded(tag, func() { r.RenderChildren(ctx, n) })
+}
+
+// text returns the current text block with all pending line breaks inserted.
+func (r *Renderer) text(ctx context.Context) *block.TextBlock {
+	text := r.State(ctx).TextBlock()
+	switch {
+	case r.breaks > 0:
+		text.EndLine(r.breaks)
+		r.breaks = 0
+	case r.space && !text.IsNewLine():
+		text.Insert(" ")
+	}
+	r.space = false
+	return text
+}
+
+// endLine ensures that the next text is inserted after at least n line
+// breaks. No line breaks are inserted if no more text follows.
+func (r *Renderer) endLine(n int) {
+	if r.breaks < n {
+		r.breaks = n
+	}
+}
+
+// finalizeBlock finalizes the current block of the state within ctx. Pending
+// line breaks and spaces are dropped, since the next text will be in a new block.
+func (r *Renderer) finalizeBlock(ctx context.Context) {
+	r.State(ctx).FinalizeBlock()
+	r.breaks = 0
+	r.space = false
+}
+
+func (r *Renderer) renderText(ctx context.Context, data string) {
+	data = collapseSpaces(data)
+
+	// Spaces are only inserted before the next text, so that whitespace
+	// around block elements doesn't end up in the output.
+	if strings.HasPrefix(data, " ") {
+		r.space = true
+		data = data[1:]
+	}
+	if data == "" {
+		return
+	}
+
+	trailing := strings.HasSuffix(data, " ")
+	data = strings.TrimSuffix(data, " ")
+
+	r.text(ctx).Insert(data)
+	r.space = trailing
+}
+
+// collapseSpaces collapses all runs of HTML whitespace into a single space.
+func collapseSpaces(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	var space bool
+	for _, r := range s {
+		switch r {
+		case ' ', '\t', '\n', '\r', '\f':
+			if !space {
+				b.WriteByte(' ')
+				space = true
+			}
+		default:
+			b.WriteRune(r)
+			space = false
+		}
+	}
+
+	return b.String()
+}
+
+// inlineTags maps inline elements to their md.Tags names.
+var inlineTags = map[atom.Atom]string{
+	atom.B:       "b",
+	atom.Strong:  "strong",
+	atom.I:       "i",
+	atom.Em:      "em",
+	atom.U:       "u",
+	atom.Del:     "del",
+	atom.Strike:  "strike",
+	atom.S:       "strike",
+	atom.Sup:     "sup",
+	atom.Sub:     "sub",
+	atom.Code:    "code",
+	atom.Caption: "caption",
+}
+
+// ignoredTags is the list of elements whose content is never rendered.
+var ignoredTags = map[atom.Atom]bool{
+	atom.Head:     true,
+	atom.Title:    true,
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Template: true,
+	atom.Noscript: true,
+	atom.Iframe:   true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Textarea: true,
+	atom.Select:   true,
+	atom.Svg:      true,
+	atom.Math:     true,
+}
+
+func (r *Renderer) renderElement(ctx context.Context, n *html.Node) {
+	if ignoredTags[n.DataAtom] {
+		return
+	}
+
+	if tagName, ok := inlineTags[n.DataAtom]; ok {
+		r.RenderChildrenWithTag(ctx, n, r.text(ctx).Tag(tagName))
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		r.endLine(2)
+		r.RenderChildrenWithTag(ctx, n, r.text(ctx).Tag(n.Data))
+		r.endLine(1)
+
+	case atom.P:
+		r.endLine(2)
+		r.RenderChildren(ctx, n)
+		r.endLine(2)
+
+	case atom.Div:
+		r.endLine(1)
+		r.RenderChildren(ctx, n)
+		r.endLine(1)
+
+	case atom.Br:
+		r.space = false
+		text := r.text(ctx)
+		text.InsertNewLines(1)
+
+	case atom.Hr:
+		r.State(ctx).Append(block.NewSeparatorBlock())
+		r.finalizeBlock(ctx)
+
+	case atom.Font, atom.Span:
+		r.renderSpan(ctx, n)
+
+	case atom.A:
+		r.renderLink(ctx, n)
+
+	case atom.Img:
+		r.renderImage(ctx, n)
+
+	case atom.Pre:
+		r.renderPre(ctx, n)
+
+	case atom.Blockquote:
+		quote := block.NewBlockquote(r.State(ctx))
+		r.State(ctx).Append(quote)
+		r.breaks, r.space = 0, false
+		r.RenderChildren(withState(ctx, quote.State), n)
+		r.finalizeBlock(ctx)
+
+	case atom.Ol, atom.Ul:
+		listIx := block.ListIndex{
+			Level:     1,
+			Index:     1,
+			Unordered: n.DataAtom == atom.Ul,
+		}
+		if start, err := strconv.Atoi(attr(n, "start")); err == nil {
+			listIx.Index = start
+		}
+		if parent, ok := ctxt.From[*block.ListIndex](ctx); ok {
+			listIx.Level = parent.Level + 1
+		}
+
+		r.RenderChildren(ctxt.With(ctx, &listIx), n)
+		r.finalizeBlock(ctx)
+
+	case atom.Li:
+		listIx, ok := ctxt.From[*block.ListIndex](ctx)
+		if !ok {
+			// Stray list item. Render it as a line.
+			r.endLine(1)
+			r.RenderChildren(ctx, n)
+			r.endLine(1)
+			return
+		}
+
+		listItem := block.NewListItemBlock(r.State(ctx), *listIx)
+		r.State(ctx).Append(listItem)
+		r.breaks, r.space = 0, false
+		r.RenderChildren(ctxt.With(withState(ctx, listItem.State()), listItem), n)
+		r.finalizeBlock(ctx)
+
+		listIx.Index++
+
+	case atom.Input:
+		// Task list items are rendered by some clients as a disabled checkbox
+		// at the start of the item.
+		listItem, ok := ctxt.From[*block.ListItemBlock](ctx)
+		if ok && attr(n, "type") == "checkbox" {
+			listItem.SetTask(hasAttr(n, "checked"))
+		}
+
+	case atom.Table:
+		table := block.NewTableBlock(r.State(ctx))
+		r.State(ctx).Append(table)
+		r.finalizeBlock(ctx)
+
+		r.RenderChildren(ctxt.With(ctx, table), n)
+
+	case atom.Tr:
+		if table, ok := ctxt.From[*block.TableBlock](ctx); ok {
+			table.AddRow(n.Parent != nil && n.Parent.DataAtom == atom.Thead)
+		}
+		r.RenderChildren(ctx, n)
+
+	case atom.Th, atom.Td:
+		table, ok := ctxt.From[*block.TableBlock](ctx)
+		if !ok {
+			r.RenderChildren(ctx, n)
+			return
+		}
+
+		cell := table.AddCell(justification(attr(n, "align")))
+		r.breaks, r.space = 0, false
+		r.RenderChildren(withState(ctx, cell), n)
+		r.breaks, r.space = 0, false
+
+	case atom.Thead, atom.Tbody, atom.Tfoot:
+		r.RenderChildren(ctx, n)
+
+	default:
+		if n.Data == "mx-reply" {
+			// Reply fallbacks are rendered by the application, not us.
+			return
+		}
+		// Unknown elements are rendered as just their content.
+		r.RenderChildren(ctx, n)
+	}
+}
+
+func (r *Renderer) renderSpan(ctx context.Context, n *html.Node) {
+	attrs := textutil.TextTag{}
+	if color := attr(n, "data-mx-color"); isColor(color) {
+		attrs["foreground"] = color
+	} else if color := attr(n, "color"); n.DataAtom == atom.Font && isColor(color) {
+		attrs["foreground"] = color
+	}
+	if color := attr(n, "data-mx-bg-color"); isColor(color) {
+		attrs["background"] = color
+	}
+
+	spoiler := hasAttr(n, "data-mx-spoiler")
+	if len(attrs) == 0 && !spoiler {
+		r.RenderChildren(ctx, n)
+		return
+	}
+
+	text := r.text(ctx)
+	startIx := text.Iter.Offset()
+	r.RenderChildren(ctx, n)
+
+	start := text.Buffer.IterAtOffset(startIx)
+
+	if len(attrs) > 0 {
+		tag := textutil.HashTag(r.State(ctx).TagTable(), attrs)
+		text.Buffer.ApplyTag(tag, start, text.Iter)
+	}
+
+	if spoiler {
+		text.ConnectSpoilerHandler()
+		// Apply this last, so it's above the colors.
+		md.AddSpoiler(text.Buffer, start, text.Iter)
+	}
+}
+
+func (r *Renderer) renderLink(ctx context.Context, n *html.Node) {
+	href := attr(n, "href")
+	if !md.IsLinkURL(href) {
+		r.RenderChildren(ctx, n)
+		return
+	}
+
+	text := r.text(ctx)
+	startIx := text.Iter.Offset()
+	r.RenderChildren(ctx, n)
+
+	// Links without any text would be impossible to click, so show the URL
+	// itself.
+	if text.Iter.Offset() == startIx {
+		text.Insert(href)
+	}
+
+	start := text.Buffer.IterAtOffset(startIx)
+	text.ApplyLink(href, start, text.Iter)
+}
+
+func (r *Renderer) renderImage(ctx context.Context, n *html.Node) {
+	alt := attr(n, "alt")
+	if alt == "" {
+		alt = attr(n, "title")
+	}
+
+	src := attr(n, "src")
+	if r.images == nil || src == "" {
+		if alt != "" {
+			r.text(ctx).Insert(alt)
+		}
+		return
+	}
+
+	w, h := DefaultImageSize, DefaultImageSize
+	if v, err := strconv.Atoi(attr(n, "width")); err == nil && v > 0 {
+		w = v
+	}
+	if v, err := strconv.Atoi(attr(n, "height")); err == nil && v > 0 {
+		h = v
+	}
+	w, h = imgutil.MaxSize(w, h, MaxImageWidth, MaxImageHeight)
+
+	text := r.text(ctx)
+
+	image := onlineimage.NewImage(r.State(ctx).Context(), r.images)
+	image.SetSizeRequest(w, h)
+	image.SetFromURL(src)
+	if alt != "" {
+		image.SetTooltipText(alt)
+	}
+
+	anchor := text.Buffer.CreateChildAnchor(text.Iter)
+	md.InsertCustomImageWidget(text.TextView, anchor, image)
+}
+
+func (r *Renderer) renderPre(ctx context.Context, n *html.Node) {
+	var language string
+	if code := n.FirstChild; code != nil && code.DataAtom == atom.Code {
+		language = codeLanguage(code)
+	}
+
+	code := block.NewCodeBlock(r.State(ctx))
+	code.TextBlock().TagNameBounded("code", func() {
+		code.TextBlock().Insert(textContent(n))
+	})
+	code.Highlight(language)
+
+	r.State(ctx).Append(code)
+	r.finalizeBlock(ctx) // no more code from here on
+}
+
+// codeLanguage returns the language of the code element from its
+// language-xxx class.
+func codeLanguage(n *html.Node) string {
+	for _, class := range strings.Fields(attr(n, "class")) {
+		if lang, ok := strings.CutPrefix(class, "language-"); ok {
+			return lang
+		}
+	}
+	return ""
+}
+
+// textContent returns the concatenated text of all text nodes inside n.
+func textContent(n *html.Node) string {
+	var b strings.Builder
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+		case html.ElementNode:
+			if n.DataAtom == atom.Br {
+				b.WriteByte('\n')
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+
+	// CRLFs screw up syntax highlighting alignment.
+	return strings.ReplaceAll(b.String(), "\r\n", "\n")
+}
+
+func justification(align string) gtk.Justification {
+	switch strings.ToLower(align) {
+	case "center":
+		return gtk.JustifyCenter
+	case "right":
+		return gtk.JustifyRight
+	default:
+		return gtk.JustifyLeft
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Namespace == "" && attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, attr := range n.Attr {
+		if attr.Namespace == "" && attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// isColor returns true if color is a #RGB or #RRGGBB hex color or a color
+// name.
+func isColor(color string) bool {
+	if hex, ok := strings.CutPrefix(color, "#"); ok {
+		if len(hex) != 3 && len(hex) != 6 {
+			return false
+		}
+		for _, r := range hex {
+			if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if color == "" {
+		return false
+	}
+	for _, r := range color {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// withState adds a new container state to the given context.
+func withState(ctx context.Context, state *block.ContainerState) context.Context {
+	return ctxt.With(ctx, state)
+}
@@ -0,0 +1,232 @@
+package htmlrender
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/diamondburned/chatkit/md"
+	"github.com/diamondburned/chatkit/md/block"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+func TestCodeLanguage(t *testing.T) {
+	tests := []struct {
+		src  string
+		lang string
+	}{
+		{`<pre><code class="language-go">package main</code></pre>`, "go"},
+		{`<pre><code class="hljs language-rust">fn main() {}</code></pre>`, "rust"},
+		{`<pre><code>plain</code></pre>`, ""},
+	}
+
+	for _, test := range tests {
+		nodes, err := Parse(test.src)
+		if err != nil {
+			t.Fatalf("cannot parse %q: %v", test.src, err)
+		}
+		if lang := codeLanguage(nodes[0].FirstChild); lang != test.lang {
+			t.Errorf("codeLanguage(%q) = %q, want %q", test.src, lang, test.lang)
+		}
+	}
+}
+
+func TestIsColor(t *testing.T) {
+	for color, valid := range map[string]bool{
+		"#ff0000":      true,
+		"#F00":         true,
+		"red":          true,
+		"":             false,
+		"#ff00":        false,
+		"#gggggg":      false,
+		"url(x)":       false,
+		"red;x":        false,
+		"rgb(1, 2, 3)": false,
+	} {
+		if isColor(color) != valid {
+			t.Errorf("isColor(%q) = %v, want %v", color, !valid, valid)
+		}
+	}
+}
+
+func TestRenderString(t *testing.T) {
+	if !gtk.InitCheck() {
+		t.Skip("cannot initialize GTK")
+	}
+
+	type check func(t *testing.T, v *block.Viewer)
+
+	tests := []struct {
+		name   string
+		src    string
+		dump   string
+		checks []check
+	}{
+		{
+			name: "mx-reply",
+			src:  `<mx-reply><blockquote><a href="https://matrix.to/#/@a:b">@a:b</a> quoted</blockquote></mx-reply>reply`,
+			dump: "text \"reply\"\n",
+		},
+		{
+			name: "colors",
+			src: `<font color="#ff0000">red</font> ` +
+				`<span data-mx-color="#00ff00" data-mx-bg-color="#000000">green</span> ` +
+				`<font color="url(x)">bad</font>`,
+			dump: "text \"red green bad\"\n",
+			checks: []check{
+				tagProp("red", "foreground-set", true),
+				tagProp("red", "background-set", false),
+				tagProp("green", "foreground-set", true),
+				tagProp("green", "background-set", true),
+				tagProp("bad", "foreground-set", false),
+			},
+		},
+		{
+			name: "code",
+			src:  `<pre><code class="language-go">package main</code></pre>`,
+			dump: "code \"package main\"\n",
+		},
+		{
+			name: "nested lists",
+			src:  `<ul><li>a<ol start="3"><li>b</li><li>c</li></ol></li><li>d</li></ul>`,
+			dump: "item •\n" +
+				"\ttext \"a\"\n" +
+				"\titem 3.\n" +
+				"\t\ttext \"b\"\n" +
+				"\titem 4.\n" +
+				"\t\ttext \"c\"\n" +
+				"item •\n" +
+				"\ttext \"d\"\n",
+		},
+		{
+			name: "spoiler",
+			src:  `<span data-mx-spoiler="reason">secret</span> shown`,
+			dump: "text \"secret shown\"\n",
+			checks: []check{
+				concealed("secret", true),
+				concealed("shown", false),
+			},
+		},
+		{
+			name: "disallowed tags",
+			src:  `<script>alert(1)</script><style>*{}</style><b>bold</b> <marquee>moving</marquee>`,
+			dump: "text \"bold moving\"\n",
+		},
+		{
+			name: "disallowed schemes",
+			src: `<a href="javascript:alert(1)">click</a> ` +
+				`<a href="file:///etc/passwd">file</a> ` +
+				`<a href="https://example.com">ok</a>`,
+			dump: "text \"click file ok\"\n",
+			checks: []check{
+				linked("click", false),
+				linked("file", false),
+				linked("ok", true),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v := block.NewViewer(context.Background())
+			if err := NewRenderer(v.State()).RenderString(context.Background(), test.src); err != nil {
+				t.Fatal("cannot render:", err)
+			}
+
+			var dump strings.Builder
+			dumpState(&dump, v.State(), 0)
+			if dump.String() != test.dump {
+				t.Errorf("unexpected blocks:\n%s\nexpected:\n%s", dump.String(), test.dump)
+			}
+
+			for _, check := range test.checks {
+				check(t, v)
+			}
+		})
+	}
+}
+
+// dumpState writes the block tree of the given state, one block per line.
+func dumpState(out *strings.Builder, state *block.ContainerState, depth int) {
+	indent := strings.Repeat("\t", depth)
+
+	state.ForEach(func(b block.WidgetBlock) bool {
+		switch b := b.(type) {
+		case *block.TextBlock:
+			fmt.Fprintf(out, "%stext %q\n", indent, textOf(b))
+		case *block.CodeBlock:
+			fmt.Fprintf(out, "%scode %q\n", indent, textOf(b.TextBlock()))
+		case *block.Blockquote:
+			fmt.Fprintf(out, "%squote\n", indent)
+			dumpState(out, b.State, depth+1)
+		case *block.ListItemBlock:
+			fmt.Fprintf(out, "%sitem %s\n", indent, b.ListIndex.Bullet())
+			dumpState(out, b.State(), depth+1)
+		default:
+			fmt.Fprintf(out, "%s%T\n", indent, b)
+		}
+		return false
+	})
+}
+
+func textOf(b *block.TextBlock) string {
+	start, end := b.Buffer.Bounds()
+	return b.Buffer.Slice(start, end, true)
+}
+
+// iterAt returns the iterator at the first occurrence of substr in the first
+// text block of v.
+func iterAt(t *testing.T, v *block.Viewer, substr string) *gtk.TextIter {
+	t.Helper()
+
+	var iter *gtk.TextIter
+	v.State().ForEach(func(b block.WidgetBlock) bool {
+		text, ok := b.(*block.TextBlock)
+		if !ok {
+			return false
+		}
+		if i := strings.Index(textOf(text), substr); i != -1 {
+			iter = text.Buffer.IterAtOffset(len([]rune(textOf(text)[:i])))
+			return true
+		}
+		return false
+	})
+
+	if iter == nil {
+		t.Fatalf("text %q not found", substr)
+	}
+	return iter
+}
+
+func tagProp(substr, prop string, want bool) func(*testing.T, *block.Viewer) {
+	return func(t *testing.T, v *block.Viewer) {
+		var got bool
+		for _, tag := range iterAt(t, v, substr).Tags() {
+			if tag.ObjectProperty(prop).(bool) {
+				got = true
+			}
+		}
+		if got != want {
+			t.Errorf("%q: expected %s = %v", substr, prop, want)
+		}
+	}
+}
+
+func concealed(substr string, want bool) func(*testing.T, *block.Viewer) {
+	return func(t *testing.T, v *block.Viewer) {
+		if md.IsConcealed(iterAt(t, v, substr)) != want {
+			t.Errorf("%q: expected concealed = %v", substr, want)
+		}
+	}
+}
+
+func linked(substr string, want bool) func(*testing.T, *block.Viewer) {
+	return func(t *testing.T, v *block.Viewer) {
+		iter := iterAt(t, v, substr)
+		tag := iter.Buffer().TagTable().Lookup("_link")
+		if got := tag != nil && iter.HasTag(tag); got != want {
+			t.Errorf("%q: expected link = %v", substr, want)
+		}
+	}
+}
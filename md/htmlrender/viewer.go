@@ -0,0 +1,30 @@
+package htmlrender
+
+import (
+	"context"
+
+	"github.com/diamondburned/chatkit/md/block"
+)
+
+// HTMLViewer extends a block.Viewer to view HTML. An HTML viewer is immutable.
+type HTMLViewer struct {
+	*block.Viewer
+}
+
+// NewHTMLViewer creates a new HTMLViewer. If the HTML cannot be parsed, then
+// an error is returned.
+func NewHTMLViewer(ctx context.Context, src string, opts ...OptionFunc) (*HTMLViewer, error) {
+	v := block.NewViewer(ctx)
+	r := NewRenderer(v.State(), opts...)
+	if err := r.RenderString(ctx, src); err != nil {
+		return nil, err
+	}
+
+	if r.jumboEmojis > 0 {
+		v.EnlargeEmojis(r.jumboEmojis)
+	}
+
+	return &HTMLViewer{
+		Viewer: v,
+	}, nil
+}
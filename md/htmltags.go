@@ -40,11 +40,21 @@ var Tags = textutil.TextTagsMap{
 		"left-margin": 12, // px
 	},
 
-	// Not an actual HTML tag.
+	// Not actual HTML tags.
 	"htmltag": {
 		"family":     "Monospace",
 		"foreground": "#808080",
 	},
+	"listmarker": {
+		"weight":     pango.WeightBold,
+		"foreground": "#808080",
+	},
+	"spoiler": {
+		"background": "rgba(128, 128, 128, 0.25)",
+	},
+	"emojishortcode": {
+		"background": "rgba(128, 128, 128, 0.15)",
+	},
 
 	// Meta tags.
 	"_invisible": {"editable": false, "invisible": true},
@@ -52,6 +62,11 @@ var Tags = textutil.TextTagsMap{
 	"_emoji":     {"scale": EmojiScale},
 	"_image":     {"rise": -2 * pango.SCALE},
 	"_nohyphens": {"insert-hyphens": false},
+	"_link":      {},
+	"_spoiler": {
+		"foreground": "#808080",
+		"background": "#808080",
+	},
 }
 
 // HTag creates a new TextTag for the heading with the given scale.
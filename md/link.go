@@ -1,52 +1,107 @@
 package md
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"strings"
+	"sync/atomic"
 
+	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/gtkutil"
 	"github.com/diamondburned/gotkit/gtkutil/textutil"
+	"libdb.so/ctxt"
 )
 
+// LinkMenuItem is an item in the context menu of links.
+type LinkMenuItem struct {
+	// Label is the label of the menu item.
+	Label string
+	// Activate is called when the menu item is activated. link is the link
+	// that the menu was opened on, and text is the visible text of the link.
+	Activate func(link EmbeddedURL, text string)
+}
+
+// LinkHandlerOpts contains options for BindLinkHandlerWithOpts.
+type LinkHandlerOpts struct {
+	// OnURL is called when a link is activated.
+	OnURL func(url string)
+	// MenuItems are added to the context menu of links after the default
+	// Open, Copy Link Address and Copy Text items.
+	MenuItems []LinkMenuItem
+	// Guard, if not nil, checks links for deception before OnURL is called.
+	Guard *LinkGuard
+}
+
+type linkMenuItems []LinkMenuItem
+
+// WithLinkMenuItems returns a new context with the given link menu items.
+// block.TextBlock adds these items to the context menu of its links.
+func WithLinkMenuItems(ctx context.Context, items ...LinkMenuItem) context.Context {
+	return ctxt.With(ctx, linkMenuItems(items))
+}
+
+// LinkMenuItemsFromContext returns the link menu items in the given context.
+func LinkMenuItemsFromContext(ctx context.Context) []LinkMenuItem {
+	items, _ := ctxt.From[linkMenuItems](ctx)
+	return items
+}
+
 // BindLinkHandler binds input handlers for triggering hyperlinks within the
 // TextView. If BindLinkHandler is called on the same TextView again, then it
 // does nothing. The function checks this by checking for the .gmd-hyperlinked
 // class.
 func BindLinkHandler(tview *gtk.TextView, onURL func(string)) {
+	BindLinkHandlerWithOpts(tview, LinkHandlerOpts{OnURL: onURL})
+}
+
+// BindLinkHandlerWithOpts is like BindLinkHandler, but it takes more options.
+// Hovering over a link shows its destination as a tooltip, and right-clicking
+// or long-pressing it opens a context menu.
+//
+// Links are also accessible with the keyboard: Tab and Shift+Tab select the
+// next and previous link before moving the focus out of the TextView, Enter
+// opens the selected link, and the Menu key or Shift+F10 opens its context
+// menu.
+//
+// Assistive technologies are only told about the selected link: its text and
+// URL are set as the accessible description of the TextView. Links are not
+// exposed as accessible objects with the link role, since the bound GTK
+// version has no API for accessible objects that aren't widgets.
+func BindLinkHandlerWithOpts(tview *gtk.TextView, opts LinkHandlerOpts) {
 	if tview.HasCSSClass("md-hyperlinked") {
 		return
 	}
 	tview.AddCSSClass("md-hyperlinked")
+	tview.SetFocusable(true)
 
 	linkTags := textutil.LinkTags()
 
-	checkURL := func(x, y float64) *EmbeddedURL {
-		bx, by := tview.WindowToBufferCoords(gtk.TextWindowWidget, int(x), int(y))
-		it, ok := tview.IterAtLocation(bx, by)
-		if !ok {
-			return nil
-		}
+	buf := tview.Buffer()
+	table := buf.TagTable()
 
-		for _, tags := range it.Tags() {
-			tagName := tags.ObjectProperty("name").(string)
+	activate := func(u *EmbeddedURL) {
+		// Only mark the link as visited once it's actually opened, since the
+		// LinkGuard dialog may be cancelled.
+		open := func(url string) {
+			opts.OnURL(url)
 
-			if !strings.HasPrefix(tagName, urlTagPrefix) {
-				continue
-			}
-
-			u, ok := ParseEmbeddedURL(strings.TrimPrefix(tagName, urlTagPrefix))
-			if ok {
-				return &u
-			}
+			tag := linkTags.FromBuffer(buf, "a:visited")
+			buf.ApplyTag(tag, buf.IterAtOffset(u.From), buf.IterAtOffset(u.To))
 		}
 
-		return nil
+		if opts.Guard != nil {
+			text := buf.Slice(buf.IterAtOffset(u.From), buf.IterAtOffset(u.To), false)
+			opts.Guard.Open(tview, text, u.URL, open)
+		} else {
+			open(u.URL)
+		}
 	}
 
-	buf := tview.Buffer()
-	table := buf.TagTable()
-
 	click := gtk.NewGestureClick()
 	click.SetButton(1)
 	click.SetExclusive(true)
@@ -55,11 +110,8 @@ func BindLinkHandler(tview *gtk.TextView, onURL func(string)) {
 			return
 		}
 
-		if u := checkURL(x, y); u != nil {
-			onURL(u.URL)
-
-			tag := linkTags.FromBuffer(buf, "a:visited")
-			buf.ApplyTag(tag, buf.IterAtOffset(u.From), buf.IterAtOffset(u.To))
+		if u := linkAtLocation(tview, x, y); u != nil {
+			activate(u)
 		}
 	})
 
@@ -81,8 +133,8 @@ func BindLinkHandler(tview *gtk.TextView, onURL func(string)) {
 		unhover()
 	})
 	motion.ConnectMotion(func(x, y float64) {
-		u := checkURL(x, y)
-		if u == lastURL {
+		u := linkAtLocation(tview, x, y)
+		if u == lastURL || (u != nil && lastURL != nil && *u == *lastURL) {
 			return
 		}
 
@@ -97,13 +149,395 @@ func BindLinkHandler(tview *gtk.TextView, onURL func(string)) {
 		}
 	})
 
+	tview.SetHasTooltip(true)
+	tview.ConnectQueryTooltip(func(x, y int, keyboard bool, tooltip *gtk.Tooltip) bool {
+		text, ok := linkTooltip(tview, x, y, keyboard)
+		if ok {
+			tooltip.SetText(text)
+		}
+		return ok
+	})
+
+	menu := linkMenu{
+		buf:   buf,
+		items: opts.MenuItems,
+		open:  activate,
+		copy:  func(text string) { tview.Clipboard().SetText(text) },
+	}
+	menuModel := menu.model()
+
+	gtkutil.BindActionMap(tview, menu.actions())
+
+	popupMenu := func(u *EmbeddedURL, x, y float64) {
+		menu.url = u
+
+		at := gdk.NewRectangle(int(x), int(y), 0, 0)
+
+		popover := gtk.NewPopoverMenuFromModel(menuModel)
+		popover.SetParent(tview)
+		popover.SetPosition(gtk.PosBottom)
+		popover.SetHasArrow(false)
+		popover.SetPointingTo(&at)
+		gtkutil.PopupFinally(popover)
+	}
+
+	showMenu := func(x, y float64) bool {
+		u := linkAtLocation(tview, x, y)
+		if u == nil {
+			return false
+		}
+
+		popupMenu(u, x, y)
+		return true
+	}
+
+	// Handle these before the TextView does, so its own context menu is only
+	// shown outside of links.
+	rightClick := gtk.NewGestureClick()
+	rightClick.SetButton(3) // secondary
+	rightClick.SetPropagationPhase(gtk.PhaseCapture)
+	rightClick.ConnectPressed(func(nPress int, x, y float64) {
+		if nPress == 1 && showMenu(x, y) {
+			rightClick.SetState(gtk.EventSequenceClaimed)
+		}
+	})
+
+	longPress := gtk.NewGestureLongPress()
+	longPress.SetTouchOnly(true)
+	longPress.SetPropagationPhase(gtk.PhaseCapture)
+	longPress.ConnectPressed(func(x, y float64) {
+		if showMenu(x, y) {
+			longPress.SetState(gtk.EventSequenceClaimed)
+		}
+	})
+
+	nav := linkKeyNav{
+		buf: buf,
+		onFocus: func(u *EmbeddedURL) {
+			if u == nil {
+				tview.ResetProperty(gtk.AccessiblePropertyDescription)
+				return
+			}
+
+			text := buf.Slice(buf.IterAtOffset(u.From), buf.IterAtOffset(u.To), false)
+			tview.UpdateProperty(
+				[]gtk.AccessibleProperty{gtk.AccessiblePropertyDescription},
+				[]coreglib.Value{*coreglib.NewValue("Link: " + text + ", " + u.URL)},
+			)
+			tview.TriggerTooltipQuery()
+		},
+	}
+
+	// Handle these before the TextView does, so Tab doesn't move the focus
+	// away until all links have been visited, and so Enter opens the selected
+	// link before the spoiler handler sees it.
+	key := gtk.NewEventControllerKey()
+	key.SetPropagationPhase(gtk.PhaseCapture)
+	key.ConnectKeyPressed(func(keyval, _ uint, state gdk.ModifierType) bool {
+		state &= gtk.AcceleratorGetDefaultModMask()
+
+		switch keyval {
+		case gdk.KEY_Tab, gdk.KEY_KP_Tab, gdk.KEY_ISO_Left_Tab:
+			switch state {
+			case 0:
+				return nav.tab(false)
+			case gdk.ShiftMask:
+				return nav.tab(true)
+			default:
+				nav.unfocus()
+				return false
+			}
+
+		case gdk.KEY_Return, gdk.KEY_KP_Enter, gdk.KEY_ISO_Enter:
+			if state != 0 {
+				return false
+			}
+			if u := nav.selected(); u != nil {
+				activate(u)
+				return true
+			}
+			return false
+
+		case gdk.KEY_Menu, gdk.KEY_F10:
+			if keyval == gdk.KEY_F10 && state != gdk.ShiftMask {
+				return false
+			}
+			u := nav.selected()
+			if u == nil {
+				return false
+			}
+			loc := tview.IterLocation(buf.IterAtOffset(u.From))
+			x, y := tview.BufferToWindowCoords(gtk.TextWindowWidget, loc.X(), loc.Y()+loc.Height())
+			popupMenu(u, float64(x), float64(y))
+			return true
+
+		default:
+			return false
+		}
+	})
+
+	focus := gtk.NewEventControllerFocus()
+	focus.ConnectLeave(func() {
+		nav.unfocus()
+	})
+
 	tview.AddController(click)
 	tview.AddController(motion)
+	tview.AddController(rightClick)
+	tview.AddController(longPress)
+	tview.AddController(key)
+	tview.AddController(focus)
+}
+
+// linkMenu is the context menu of the links of a buffer.
+type linkMenu struct {
+	buf   *gtk.TextBuffer
+	items []LinkMenuItem
+	// open opens the given link.
+	open func(*EmbeddedURL)
+	// copy copies the given text to the clipboard.
+	copy func(string)
+	// url is the link that the menu was last opened on.
+	url *EmbeddedURL
+}
+
+// model returns the menu model. Its items activate the actions returned by
+// actions.
+func (m *linkMenu) model() *gio.Menu {
+	menu := gio.NewMenu()
+	menu.Append("Open", "mdlink.open")
+	menu.Append("Copy Link Address", "mdlink.copy-url")
+	menu.Append("Copy Text", "mdlink.copy-text")
+
+	if len(m.items) > 0 {
+		section := gio.NewMenu()
+		for i, item := range m.items {
+			section.Append(item.Label, fmt.Sprintf("mdlink.item-%d", i))
+		}
+		menu.AppendSection("", section)
+	}
+
+	return menu
+}
+
+// actions returns the actions of the menu items, keyed by their detailed
+// action names.
+func (m *linkMenu) actions() map[string]func() {
+	actions := map[string]func(){
+		"mdlink.open":      func() { m.open(m.url) },
+		"mdlink.copy-url":  func() { m.copy(m.url.URL) },
+		"mdlink.copy-text": func() { m.copy(m.text()) },
+	}
+
+	for i, item := range m.items {
+		item := item
+		actions[fmt.Sprintf("mdlink.item-%d", i)] = func() {
+			item.Activate(*m.url, m.text())
+		}
+	}
+
+	return actions
+}
+
+// text returns the visible text of the link that the menu was opened on.
+func (m *linkMenu) text() string {
+	return m.buf.Slice(m.buf.IterAtOffset(m.url.From), m.buf.IterAtOffset(m.url.To), false)
+}
+
+// linkTooltip returns the tooltip text of the link at the given widget
+// coordinates, or at the cursor if keyboard is true. False is returned if
+// there's no link there.
+func linkTooltip(tview *gtk.TextView, x, y int, keyboard bool) (string, bool) {
+	var u *EmbeddedURL
+	if keyboard {
+		buf := tview.Buffer()
+		u = urlAtIter(buf.IterAtMark(buf.GetInsert()))
+	} else {
+		u = linkAtLocation(tview, float64(x), float64(y))
+	}
+	if u == nil {
+		return "", false
+	}
+	return u.URL, true
+}
+
+// linkAtLocation returns the link at the given widget coordinates, or nil if
+// there's none.
+func linkAtLocation(tview *gtk.TextView, x, y float64) *EmbeddedURL {
+	bx, by := tview.WindowToBufferCoords(gtk.TextWindowWidget, int(x), int(y))
+	it, ok := tview.IterAtLocation(bx, by)
+	if !ok {
+		return nil
+	}
+	return urlAtIter(it)
+}
+
+// linkKeyNav moves the selection between the links of a buffer using the
+// keyboard.
+type linkKeyNav struct {
+	buf *gtk.TextBuffer
+	// onFocus is called when a link is selected, or with nil when the
+	// selected link is unselected.
+	onFocus func(*EmbeddedURL)
+	// focused is the link that was last selected using the keyboard.
+	focused *EmbeddedURL
+}
+
+// tab selects the next link, or the previous link if backward is true. If
+// there's none, then the selected link is unselected and false is returned, so
+// the focus can move out of the TextView.
+func (n *linkKeyNav) tab(backward bool) bool {
+	var u *EmbeddedURL
+	if backward {
+		to := n.buf.IterAtMark(n.buf.GetInsert()).Offset()
+		if n.focused != nil {
+			to = n.focused.From
+		}
+		u = previousLink(n.buf, to)
+	} else {
+		from := n.buf.IterAtMark(n.buf.GetInsert()).Offset()
+		if n.focused != nil {
+			from = n.focused.From + 1
+		}
+		u = nextLink(n.buf, from)
+	}
+
+	if u == nil {
+		n.unfocus()
+		return false
+	}
+
+	n.focused = u
+	n.buf.SelectRange(n.buf.IterAtOffset(u.From), n.buf.IterAtOffset(u.To))
+	if n.onFocus != nil {
+		n.onFocus(u)
+	}
+	return true
+}
+
+// unfocus unselects the link selected using tab, if any.
+func (n *linkKeyNav) unfocus() {
+	if n.focused == nil {
+		return
+	}
+	n.focused = nil
+
+	insert := n.buf.IterAtMark(n.buf.GetInsert())
+	n.buf.SelectRange(insert, insert)
+	if n.onFocus != nil {
+		n.onFocus(nil)
+	}
+}
+
+// selected returns the link at the cursor, which is the one that Enter opens,
+// or nil if there's none.
+func (n *linkKeyNav) selected() *EmbeddedURL {
+	return urlAtIter(n.buf.IterAtMark(n.buf.GetInsert()))
+}
+
+// nextLink returns the first link that starts at or after the given offset, or
+// nil if there's none. Concealed links are skipped.
+func nextLink(buf *gtk.TextBuffer, offset int) *EmbeddedURL {
+	var next *EmbeddedURL
+	if index := bufferLinkIndex(buf, false); index != nil {
+		if u, ok := index.next(buf, offset); ok {
+			next = &u
+		}
+	}
+
+	if !hasURLTagNames.Load() {
+		return next
+	}
+
+	// Links tagged using URLTagName each have their own tag, so they can be
+	// found by their tag toggles.
+	it := buf.IterAtOffset(offset)
+	for next == nil || it.Offset() < next.From {
+		if u := urlTagAtIter(it); u != nil && u.From >= offset {
+			return u
+		}
+		if !it.ForwardToTagToggle(nil) {
+			break
+		}
+	}
+
+	return next
+}
+
+// previousLink returns the last link that starts before the given offset, or
+// nil if there's none. Concealed links are skipped.
+func previousLink(buf *gtk.TextBuffer, offset int) *EmbeddedURL {
+	var prev *EmbeddedURL
+	if index := bufferLinkIndex(buf, false); index != nil {
+		if u, ok := index.previous(buf, offset); ok {
+			prev = &u
+		}
+	}
+
+	if !hasURLTagNames.Load() {
+		return prev
+	}
+
+	it := buf.IterAtOffset(offset)
+	for it.BackwardToTagToggle(nil) {
+		if prev != nil && it.Offset() < prev.From {
+			break
+		}
+		if u := urlTagAtIter(it); u != nil && u.From < offset {
+			if prev == nil || u.From > prev.From {
+				return u
+			}
+			break
+		}
+	}
+
+	return prev
+}
+
+// urlAtIter returns the link at the given iterator, or nil if there's none or
+// if it's concealed.
+func urlAtIter(it *gtk.TextIter) *EmbeddedURL {
+	if IsConcealed(it) {
+		return nil
+	}
+
+	if u, ok := linkAtIter(it); ok {
+		return &u
+	}
+
+	return urlTagAtIter(it)
+}
+
+// urlTagAtIter returns the link tagged using URLTagName at the given iterator,
+// or nil if there's none or if it's concealed.
+func urlTagAtIter(it *gtk.TextIter) *EmbeddedURL {
+	if !hasURLTagNames.Load() || IsConcealed(it) {
+		return nil
+	}
+
+	for _, tags := range it.Tags() {
+		tagName := tags.ObjectProperty("name").(string)
+
+		if !strings.HasPrefix(tagName, urlTagPrefix) {
+			continue
+		}
+
+		u, ok := ParseEmbeddedURL(strings.TrimPrefix(tagName, urlTagPrefix))
+		if ok {
+			return &u
+		}
+	}
+
+	return nil
 }
 
 // urlTagPrefix is the prefix for tag names that identify a hyperlinked URL.
 const urlTagPrefix = "link:"
 
+// hasURLTagNames is true once URLTagName is called. Only then do tag names
+// need to be checked for links.
+var hasURLTagNames atomic.Bool
+
 // EmbeddedURL is a type that describes a URL and its bounds within a text
 // buffer.
 type EmbeddedURL struct {
@@ -112,8 +546,11 @@ type EmbeddedURL struct {
 	URL  string `json:"u"`
 }
 
-// URLTagName creates a new URL tag name from the given URL.
+// URLTagName creates a new URL tag name from the given URL. Tags with this
+// name are still recognized as links, but each of them is a new tag in the
+// buffer's tag table. Use AddLink instead.
 func URLTagName(start, end *gtk.TextIter, url string) string {
+	hasURLTagNames.Store(true)
 	return urlTagPrefix + embedURL(start.Offset(), end.Offset(), url)
 }
 
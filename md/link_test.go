@@ -0,0 +1,185 @@
+package md
+
+import (
+	"testing"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+func TestLinkKeyNav(t *testing.T) {
+	if !gtk.InitCheck() {
+		t.Skip("cannot initialize GTK")
+	}
+
+	buf := gtk.NewTextBuffer(nil)
+	buf.SetText("see a and bc here")
+	AddLink(buf, buf.IterAtOffset(4), buf.IterAtOffset(5), "https://a.example")
+	// Adjacent links share the same tag, so there's no tag toggle between
+	// them.
+	AddLink(buf, buf.IterAtOffset(10), buf.IterAtOffset(11), "https://b.example")
+	AddLink(buf, buf.IterAtOffset(11), buf.IterAtOffset(12), "https://c.example")
+
+	var focused *EmbeddedURL
+	nav := linkKeyNav{
+		buf:     buf,
+		onFocus: func(u *EmbeddedURL) { focused = u },
+	}
+
+	// step presses Tab or Shift+Tab and checks the link that is selected
+	// afterwards, if any.
+	step := func(backward bool, url string) {
+		t.Helper()
+
+		if ok := nav.tab(backward); ok != (url != "") {
+			t.Fatalf("tab(%v) = %v, want a link %q", backward, ok, url)
+		}
+
+		if url == "" {
+			if focused != nil || nav.focused != nil {
+				t.Fatalf("link %+v is still focused", focused)
+			}
+			if buf.HasSelection() {
+				t.Fatal("text is still selected")
+			}
+			return
+		}
+
+		if focused == nil || focused.URL != url {
+			t.Fatalf("focused link is %+v, want %q", focused, url)
+		}
+		// Enter opens the selected link.
+		if u := nav.selected(); u == nil || *u != *focused {
+			t.Fatalf("Enter opens %+v, want %+v", u, focused)
+		}
+		start, end, _ := buf.SelectionBounds()
+		if start.Offset() != focused.From || end.Offset() != focused.To {
+			t.Fatalf("selection is [%d, %d), want %+v", start.Offset(), end.Offset(), focused)
+		}
+	}
+
+	buf.PlaceCursor(buf.StartIter())
+	if u := nav.selected(); u != nil {
+		t.Fatalf("Enter opens %+v outside of links", u)
+	}
+
+	step(false, "https://a.example")
+	step(false, "https://b.example")
+	step(false, "https://c.example")
+	step(false, "")
+
+	buf.PlaceCursor(buf.EndIter())
+	step(true, "https://c.example")
+	step(true, "https://b.example")
+	step(true, "https://a.example")
+	step(true, "")
+
+	// Moving the focus away unselects the link.
+	step(false, "https://a.example")
+	nav.unfocus()
+	if focused != nil || buf.HasSelection() {
+		t.Errorf("link %+v is still focused after unfocus", focused)
+	}
+}
+
+func TestLinkTooltip(t *testing.T) {
+	if !gtk.InitCheck() {
+		t.Skip("cannot initialize GTK")
+	}
+
+	tview := gtk.NewTextView()
+	buf := tview.Buffer()
+	buf.SetText("see a here")
+	AddLink(buf, buf.IterAtOffset(4), buf.IterAtOffset(5), "https://a.example")
+
+	tests := []struct {
+		name   string
+		cursor int
+		want   string
+	}{
+		{"before link", 0, ""},
+		{"in link", 4, "https://a.example"},
+		{"after link", 7, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf.PlaceCursor(buf.IterAtOffset(test.cursor))
+
+			text, ok := linkTooltip(tview, 0, 0, true)
+			if ok != (test.want != "") || text != test.want {
+				t.Errorf("linkTooltip = (%q, %v), want %q", text, ok, test.want)
+			}
+		})
+	}
+}
+
+func TestLinkMenu(t *testing.T) {
+	if !gtk.InitCheck() {
+		t.Skip("cannot initialize GTK")
+	}
+
+	buf := gtk.NewTextBuffer(nil)
+	buf.SetText("see the docs here")
+	AddLink(buf, buf.IterAtOffset(4), buf.IterAtOffset(12), "https://docs.example")
+
+	var (
+		opened  *EmbeddedURL
+		copied  string
+		itemURL EmbeddedURL
+		itemTxt string
+	)
+
+	menu := linkMenu{
+		buf: buf,
+		items: []LinkMenuItem{{
+			Label: "Share",
+			Activate: func(link EmbeddedURL, text string) {
+				itemURL = link
+				itemTxt = text
+			},
+		}},
+		open: func(u *EmbeddedURL) { opened = u },
+		copy: func(text string) { copied = text },
+	}
+
+	// The default items, then a section with the custom item.
+	if n := menu.model().NItems(); n != 4 {
+		t.Errorf("menu has %d items, want 4", n)
+	}
+
+	menu.url = urlAtIter(buf.IterAtOffset(5))
+	if menu.url == nil {
+		t.Fatal("no link at offset 5")
+	}
+
+	actions := menu.actions()
+	activate := func(name string) {
+		t.Helper()
+
+		action, ok := actions[name]
+		if !ok {
+			t.Fatalf("missing action %q", name)
+		}
+		action()
+	}
+
+	activate("mdlink.open")
+	if opened != menu.url {
+		t.Errorf("open opened %+v, want %+v", opened, menu.url)
+	}
+
+	activate("mdlink.copy-url")
+	if copied != "https://docs.example" {
+		t.Errorf("copy-url copied %q", copied)
+	}
+
+	activate("mdlink.copy-text")
+	if copied != "the docs" {
+		t.Errorf("copy-text copied %q", copied)
+	}
+
+	activate("mdlink.item-0")
+	if itemURL != *menu.url || itemTxt != "the docs" {
+		t.Errorf("item got (%+v, %q), want (%+v, %q)", itemURL, itemTxt, *menu.url, "the docs")
+	}
+}
@@ -0,0 +1,369 @@
+package md
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+	"libdb.so/ctxt"
+)
+
+// SafeLinkSchemes is the list of URL schemes that LinkGuard opens without
+// asking.
+var SafeLinkSchemes = []string{"https", "http", "mailto"}
+
+// LinkWarning is a set of reasons why a link may be deceptive.
+type LinkWarning uint8
+
+const (
+	// LinkTextMismatch is set when the text of the link looks like a URL that
+	// points to a different host than the link does.
+	LinkTextMismatch LinkWarning = 1 << iota
+	// LinkConfusableHost is set when a label of the host of the link mixes
+	// characters of different scripts, or only uses Cyrillic or Greek
+	// characters that look like Latin letters, so it may imitate another
+	// host. Other internationalized domain names aren't warned about.
+	LinkConfusableHost
+	// LinkUnsafeScheme is set when the scheme of the link is not in
+	// SafeLinkSchemes, so it may open another application.
+	LinkUnsafeScheme
+)
+
+// Has returns true if w has all of the given warnings.
+func (w LinkWarning) Has(warning LinkWarning) bool {
+	return w&warning == warning
+}
+
+// CheckLink checks whether a link with the given visible text and destination
+// URL may be deceptive. Zero is returned if the link looks safe.
+func CheckLink(text, rawURL string) LinkWarning {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return LinkUnsafeScheme
+	}
+
+	var w LinkWarning
+
+	if !isSafeLinkScheme(u.Scheme) {
+		w |= LinkUnsafeScheme
+	}
+
+	host := normalizeHost(u.Hostname())
+	if isConfusableHost(host) {
+		w |= LinkConfusableHost
+	}
+
+	if textHost, ok := linkTextHost(text); ok && host != "" && !isSameSite(textHost, host) {
+		w |= LinkTextMismatch
+	}
+
+	return w
+}
+
+// LinkHost returns the normalized host of the given URL, or an empty string if
+// it has none. Hosts are lowercased and converted to their ASCII form.
+func LinkHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return normalizeHost(u.Hostname())
+}
+
+func isSafeLinkScheme(scheme string) bool {
+	for _, safe := range SafeLinkSchemes {
+		if strings.EqualFold(scheme, safe) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeHost(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if ascii, err := idna.ToASCII(host); err == nil {
+		host = ascii
+	}
+	return host
+}
+
+// isConfusableHost returns true if any label of the given ASCII host may
+// imitate another label. See LinkConfusableHost.
+func isConfusableHost(host string) bool {
+	for _, label := range strings.Split(host, ".") {
+		if !strings.HasPrefix(label, "xn--") {
+			// Hosts that couldn't be converted to ASCII aren't trusted.
+			if strings.IndexFunc(label, isNonASCII) != -1 {
+				return true
+			}
+			continue
+		}
+
+		u, err := idna.Punycode.ToUnicode(label)
+		if err != nil || isConfusableLabel(u) {
+			return true
+		}
+	}
+	return false
+}
+
+func isNonASCII(r rune) bool { return r >= utf8.RuneSelf }
+
+// latinLookalikes is the Cyrillic and Greek lowercase letters that look like
+// Latin letters.
+const latinLookalikes = "аеіјорсухѕһԁԛԝӏ" + "αεικνορτυχ"
+
+// isConfusableLabel returns true if the given Unicode label mixes scripts, or
+// if it only uses Cyrillic or Greek letters that look like Latin letters.
+// Mixing Latin with Chinese, Japanese or Korean is common, so it's allowed.
+func isConfusableLabel(label string) bool {
+	scripts := make(map[string]bool, 2)
+	lookalike := true
+
+	for _, r := range label {
+		if !unicode.IsLetter(r) || unicode.In(r, unicode.Common, unicode.Inherited) {
+			continue
+		}
+		scripts[letterScript(r)] = true
+		if !strings.ContainsRune(latinLookalikes, r) {
+			lookalike = false
+		}
+	}
+
+	switch len(scripts) {
+	case 0:
+		return false
+	case 1:
+		return lookalike && (scripts["Cyrillic"] || scripts["Greek"])
+	case 2:
+		return !(scripts["Latin"] && scripts["CJK"])
+	default:
+		return true
+	}
+}
+
+// letterScript returns the name of the script of the given letter. Chinese,
+// Japanese and Korean scripts are all named CJK, since they're mixed.
+func letterScript(r rune) string {
+	switch {
+	case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Bopomofo):
+		return "CJK"
+	case unicode.Is(unicode.Latin, r):
+		return "Latin"
+	}
+	for name, table := range unicode.Scripts {
+		if unicode.Is(table, r) {
+			return name
+		}
+	}
+	return ""
+}
+
+// linkTextHost returns the host of the link text if the text looks like a URL
+// or a domain.
+func linkTextHost(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" || strings.IndexFunc(text, unicode.IsSpace) != -1 {
+		return "", false
+	}
+
+	if !strings.Contains(text, "://") {
+		text = "http://" + text
+	}
+
+	u, err := url.Parse(text)
+	if err != nil || u.Hostname() == "" || u.User != nil {
+		return "", false
+	}
+
+	host := u.Hostname()
+	if net.ParseIP(host) == nil {
+		// Require a top-level domain of only letters, so things like version
+		// numbers aren't mistaken as domains.
+		dot := strings.LastIndexByte(host, '.')
+		if dot < 1 || dot == len(host)-1 {
+			return "", false
+		}
+		for _, r := range host[dot+1:] {
+			if !unicode.IsLetter(r) {
+				return "", false
+			}
+		}
+	}
+
+	return normalizeHost(host), true
+}
+
+// isSameSite returns true if both hosts belong to the same registrable domain,
+// so "docs.example.com" and "example.com" are the same site, but
+// "attacker.github.io" and "github.io" are not.
+func isSameSite(a, b string) bool {
+	return registrableDomain(a) == registrableDomain(b)
+}
+
+// registrableDomain returns the public suffix of host plus one more label, or
+// host itself if there's none, such as when host is an IP address or a public
+// suffix.
+func registrableDomain(host string) string {
+	host = strings.TrimPrefix(host, "www.")
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	if domain, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return domain
+	}
+	return host
+}
+
+// LinkGuard asks the user for confirmation before opening links that may be
+// deceptive. Hosts that the user trusts are opened without asking. A
+// LinkGuard must only be used from the main thread. The zero value is a
+// LinkGuard that trusts no hosts.
+type LinkGuard struct {
+	// OnTrust, if not nil, is called when the user chooses to trust a host,
+	// so that it can be persisted.
+	OnTrust func(host string)
+
+	trusted map[string]struct{}
+}
+
+// NewLinkGuard creates a new LinkGuard that trusts the given hosts.
+func NewLinkGuard(trusted ...string) *LinkGuard {
+	g := &LinkGuard{trusted: make(map[string]struct{}, len(trusted))}
+	for _, host := range trusted {
+		g.trusted[normalizeHost(host)] = struct{}{}
+	}
+	return g
+}
+
+// Trust trusts the given host.
+func (g *LinkGuard) Trust(host string) {
+	if g.trusted == nil {
+		g.trusted = make(map[string]struct{})
+	}
+	g.trusted[normalizeHost(host)] = struct{}{}
+}
+
+// IsTrusted returns true if the given host is trusted.
+func (g *LinkGuard) IsTrusted(host string) bool {
+	_, ok := g.trusted[normalizeHost(host)]
+	return ok
+}
+
+// trustedWarnings is the warnings that trusting a host silences. Links whose
+// text points elsewhere are still deceptive even if their host is trusted.
+const trustedWarnings = LinkConfusableHost | LinkUnsafeScheme
+
+// Check is like CheckLink, except links to trusted hosts are only warned about
+// if their text points to another host.
+func (g *LinkGuard) Check(text, rawURL string) LinkWarning {
+	w := CheckLink(text, rawURL)
+	if host := LinkHost(rawURL); host != "" && g.IsTrusted(host) {
+		w &^= trustedWarnings
+	}
+	return w
+}
+
+// Open calls open with the given URL if the link is safe. Otherwise, a dialog
+// that explains why the link may be deceptive is shown over the window of
+// parent, and open is only called if the user confirms.
+func (g *LinkGuard) Open(parent gtk.Widgetter, text, rawURL string, open func(url string)) {
+	warning := g.Check(text, rawURL)
+	if warning == 0 {
+		open(rawURL)
+		return
+	}
+
+	var window *gtk.Window
+	if root := gtk.BaseWidget(parent).Root(); root != nil {
+		window, _ = root.CastType(gtk.GTypeWindow).(*gtk.Window)
+	}
+
+	host := LinkHost(rawURL)
+
+	dialog := adw.NewMessageDialog(window, "Open Link?", "")
+	dialog.SetBodyUseMarkup(true)
+	dialog.SetBody(linkWarningBody(warning, text, rawURL, host))
+	dialog.AddResponse("cancel", "Cancel")
+	dialog.AddResponse("open", "Open")
+	dialog.SetResponseAppearance("open", adw.ResponseDestructive)
+	dialog.SetDefaultResponse("cancel")
+	dialog.SetCloseResponse("cancel")
+
+	var trust *gtk.CheckButton
+	if host != "" && warning&trustedWarnings != 0 {
+		trust = gtk.NewCheckButtonWithLabel("Always trust " + displayHost(host))
+		dialog.SetExtraChild(trust)
+	}
+
+	dialog.ConnectResponse(func(response string) {
+		if response != "open" {
+			return
+		}
+
+		if trust != nil && trust.Active() {
+			g.Trust(host)
+			if g.OnTrust != nil {
+				g.OnTrust(host)
+			}
+		}
+
+		open(rawURL)
+	})
+
+	dialog.Present()
+}
+
+// linkWarningBody returns the Pango markup that explains the given warnings.
+func linkWarningBody(w LinkWarning, text, rawURL, host string) string {
+	var reasons []string
+
+	if w.Has(LinkTextMismatch) {
+		reasons = append(reasons, fmt.Sprintf(
+			"The link shows <b>%s</b>, but it goes to <b>%s</b>.",
+			html.EscapeString(text), html.EscapeString(displayHost(host))))
+	}
+	if w.Has(LinkConfusableHost) {
+		reasons = append(reasons, fmt.Sprintf(
+			"The address <b>%s</b> (%s) contains international characters "+
+				"that may imitate another address.",
+			html.EscapeString(displayHost(host)), html.EscapeString(host)))
+	}
+	if w.Has(LinkUnsafeScheme) {
+		reasons = append(reasons,
+			"This is not a web link, so it may open another application.")
+	}
+
+	reasons = append(reasons, "<tt>"+html.EscapeString(rawURL)+"</tt>")
+	return strings.Join(reasons, "\n\n")
+}
+
+// displayHost returns the Unicode form of the given host.
+func displayHost(host string) string {
+	if u, err := idna.ToUnicode(host); err == nil {
+		return u
+	}
+	return host
+}
+
+// WithLinkGuard returns a new context with the given LinkGuard. block.TextBlock
+// uses it to check links before opening them.
+func WithLinkGuard(ctx context.Context, guard *LinkGuard) context.Context {
+	return ctxt.With(ctx, guard)
+}
+
+// LinkGuardFromContext returns the LinkGuard in the given context, or nil if
+// there's none.
+func LinkGuardFromContext(ctx context.Context) *LinkGuard {
+	guard, _ := ctxt.From[*LinkGuard](ctx)
+	return guard
+}
@@ -0,0 +1,73 @@
+package md
+
+import "testing"
+
+func TestCheckLink(t *testing.T) {
+	tests := []struct {
+		text string
+		url  string
+		want LinkWarning
+	}{
+		{"click here", "https://example.com", 0},
+		{"example.com", "https://example.com/path", 0},
+		{"https://www.example.com", "https://example.com", 0},
+		{"example.com", "https://docs.example.com", 0},
+		{"version 1.2", "https://example.com", 0},
+		{"mail me", "mailto:me@example.com", 0},
+		{"paypal.com", "https://evil.example", LinkTextMismatch},
+		{"https://github.com/login", "https://github.com.evil.example/login", LinkTextMismatch},
+		{"github.io", "https://attacker.github.io", LinkTextMismatch},
+		{"alice.github.io", "https://bob.github.io", LinkTextMismatch},
+		{"docs.github.io", "https://docs.github.io/page", 0},
+		{"example.co.uk", "https://shop.example.co.uk", 0},
+		{"127.0.0.1", "http://127.0.0.1:8080", 0},
+		{"apple", "https://xn--pple-43d.com", LinkConfusableHost},
+		{"apple", "https://аpple.com", LinkConfusableHost},
+		{"apple.com", "https://аpple.com", LinkTextMismatch | LinkConfusableHost},
+		{"copoc", "https://сорос.com", LinkConfusableHost},
+		{"münchen", "https://münchen.de", 0},
+		{"пример", "https://пример.рф", 0},
+		{"例え", "https://例え.jp", 0},
+		{"ελληνικά", "https://ελληνικά.gr", 0},
+		{"sony", "https://sonyソニー.jp", 0},
+		{"run this", "file:///etc/passwd", LinkUnsafeScheme},
+		{"javascript", "javascript:alert(1)", LinkUnsafeScheme},
+	}
+
+	for _, test := range tests {
+		if got := CheckLink(test.text, test.url); got != test.want {
+			t.Errorf("CheckLink(%q, %q) = %03b, want %03b", test.text, test.url, got, test.want)
+		}
+	}
+}
+
+func TestLinkGuardTrust(t *testing.T) {
+	g := NewLinkGuard("Evil.Example.")
+	if w := g.Check("run this", "evil+app://evil.example"); w != 0 {
+		t.Errorf("trusted host is warned: %03b", w)
+	}
+	if w := g.Check("run this", "evil+app://other.example"); w != LinkUnsafeScheme {
+		t.Errorf("untrusted host is not warned: %03b", w)
+	}
+	// Trusting a host doesn't trust links that pretend to go elsewhere.
+	if w := g.Check("https://bank.com", "https://evil.example/login"); w != LinkTextMismatch {
+		t.Errorf("mismatched link to a trusted host is not warned: %03b", w)
+	}
+}
+
+func TestLinkGuardZero(t *testing.T) {
+	var trusted []string
+	g := &LinkGuard{OnTrust: func(host string) { trusted = append(trusted, host) }}
+
+	if g.IsTrusted("example.com") {
+		t.Error("zero LinkGuard trusts a host")
+	}
+
+	g.Trust("Example.com")
+	if !g.IsTrusted("example.com") {
+		t.Error("host is not trusted after Trust")
+	}
+	if w := g.Check("run this", "evil+app://example.com"); w != 0 {
+		t.Errorf("trusted host is warned: %03b", w)
+	}
+}
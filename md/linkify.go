@@ -0,0 +1,109 @@
+package md
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// LinkifyOpts contains options for NewLinkifyParser.
+type LinkifyOpts struct {
+	// WWW, if true, also linkifies hosts that start with "www." without a
+	// scheme, such as www.example.com. They link to http.
+	WWW bool
+}
+
+// linkifyURLRegexp is goldmark's URL regular expression, except quotes, angle
+// brackets, square brackets and braces are not allowed in the URL, since they
+// usually surround the URL instead.
+var linkifyURLRegexp = regexp.MustCompile(`^(?:http|https|ftp)://[-a-zA-Z0-9@:%._\+~#=]{1,256}\.[a-z]+(?::\d+)?(?:[/#?][-a-zA-Z0-9@:%_+.~#$!?&/=\(\);,]*)?`)
+
+// linkifyWWWRegexp is linkifyURLRegexp for www hosts.
+var linkifyWWWRegexp = regexp.MustCompile(`^www\.[-a-zA-Z0-9@:%._\+~#=]{1,256}\.[a-z]+(?::\d+)?(?:[/#?][-a-zA-Z0-9@:%_+.~#$!?&/=\(\);,]*)?`)
+
+// neverRegexp never matches anything.
+var neverRegexp = regexp.MustCompile(`a^`)
+
+type linkifyParser struct {
+	parser.InlineParser
+}
+
+// NewLinkifyParser returns a new InlineParser that parses bare http, https and
+// ftp URLs and email addresses into AutoLink nodes. Trailing punctuation is
+// not included in the link, and neither are closing parentheses that aren't
+// opened within the URL, so "(see https://example.com)." works as expected.
+func NewLinkifyParser(opts LinkifyOpts) parser.InlineParser {
+	wwwRegexp := neverRegexp
+	if opts.WWW {
+		wwwRegexp = linkifyWWWRegexp
+	}
+
+	return linkifyParser{extension.NewLinkifyParser(
+		extension.WithLinkifyURLRegexp(linkifyURLRegexp),
+		extension.WithLinkifyWWWRegexp(wwwRegexp),
+	)}
+}
+
+func (p linkifyParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.Position()
+
+	node := p.InlineParser.Parse(parent, block, pc)
+
+	n, ok := node.(*ast.AutoLink)
+	if !ok || n.AutoLinkType != ast.AutoLinkURL {
+		return node
+	}
+
+	// The label is right before the current position.
+	_, after := block.Position()
+	label := n.Label(block.Source())
+	start := after.Start - len(label)
+
+	// goldmark only trims either the trailing punctuation or the unopened
+	// parentheses, so trim the rest here.
+	trimmed := trimURL(label)
+	if trimmed == len(label) {
+		return n
+	}
+
+	block.SetPosition(line, segment)
+	block.Advance(start + trimmed - segment.Start)
+
+	link := ast.NewAutoLink(n.AutoLinkType, ast.NewTextSegment(text.NewSegment(start, start+trimmed)))
+	link.Protocol = n.Protocol
+	return link
+}
+
+// trimURL returns the length of url without its trailing punctuation and
+// unopened closing parentheses.
+func trimURL(url []byte) int {
+	n := len(url)
+	for n > 0 {
+		switch url[n-1] {
+		case '?', '!', '.', ',', ':', '*', '_', '~':
+			n--
+		case ')':
+			if bytes.Count(url[:n], []byte(")")) <= bytes.Count(url[:n], []byte("(")) {
+				return n
+			}
+			n--
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// AutoLinkURL returns the URL that the given AutoLink node links to. Unlike
+// n.URL, email addresses are prefixed with "mailto:".
+func AutoLinkURL(n *ast.AutoLink, src []byte) string {
+	url := n.URL(src)
+	if n.AutoLinkType == ast.AutoLinkEmail && !bytes.HasPrefix(bytes.ToLower(url), []byte("mailto:")) {
+		return "mailto:" + string(url)
+	}
+	return string(url)
+}
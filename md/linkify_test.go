@@ -0,0 +1,52 @@
+package md
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestConverterLinkify(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{
+			name: "url",
+			in:   "see https://example.com/a?b=c.",
+			out:  "<p>see <a href=\"https://example.com/a?b=c\">https://example.com/a?b=c</a>.</p>\n",
+		},
+		{
+			name: "parentheses",
+			in:   "(https://example.com/a_(b)), (https://example.com/c).",
+			out:  "<p>(<a href=\"https://example.com/a_(b)\">https://example.com/a_(b)</a>), (<a href=\"https://example.com/c\">https://example.com/c</a>).</p>\n",
+		},
+		{
+			name: "www",
+			in:   "www.example.com!",
+			out:  "<p><a href=\"http://www.example.com\">www.example.com</a>!</p>\n",
+		},
+		{
+			name: "email",
+			in:   "mail me@example.com.",
+			out:  "<p>mail <a href=\"mailto:me@example.com\">me@example.com</a>.</p>\n",
+		},
+		{
+			name: "code span",
+			in:   "`https://example.com`",
+			out:  "<p><code>https://example.com</code></p>\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := Converter.Convert([]byte(test.in), &out); err != nil {
+				t.Fatal("cannot convert:", err)
+			}
+			if out.String() != test.out {
+				t.Errorf("unexpected output:\n got %q\nwant %q", out.String(), test.out)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package md
+
+import (
+	"sort"
+	"sync"
+
+	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// linkTagName is the name of the tag that is applied to all links added using
+// AddLink. The tag itself doesn't say which link it is; the buffer's link index
+// does.
+const linkTagName = "_link"
+
+// indexedLink is a link within a buffer. Its bounds are kept as marks, so they
+// stay correct when the buffer is edited.
+type indexedLink struct {
+	start *gtk.TextMark
+	end   *gtk.TextMark
+	url   string
+}
+
+func (l indexedLink) embeddedURL(buf *gtk.TextBuffer) EmbeddedURL {
+	return EmbeddedURL{
+		From: buf.IterAtMark(l.start).Offset(),
+		To:   buf.IterAtMark(l.end).Offset(),
+		URL:  l.url,
+	}
+}
+
+// linkIndex is the side table of links within a buffer, sorted by their start
+// offsets. Links never overlap. Links whose text is deleted are removed from
+// the index, so it doesn't grow forever in buffers that are edited.
+type linkIndex struct {
+	links []indexedLink
+	// dead is the marks of removed links, which are deleted once the buffer
+	// is done changing.
+	dead []*gtk.TextMark
+}
+
+var (
+	linkIndexMu sync.Mutex
+	linkIndexes = map[uintptr]*linkIndex{}
+)
+
+// bufferLinkIndex returns the link index of the given buffer. If create is
+// true, then a new index is created if the buffer doesn't have one yet.
+// Otherwise, nil is returned.
+func bufferLinkIndex(buf *gtk.TextBuffer, create bool) *linkIndex {
+	key := coreglib.InternObject(buf).Native()
+
+	linkIndexMu.Lock()
+	defer linkIndexMu.Unlock()
+
+	index, ok := linkIndexes[key]
+	if !ok && create {
+		index = &linkIndex{}
+		linkIndexes[key] = index
+
+		buf.ConnectDeleteRange(func(start, end *gtk.TextIter) {
+			index.prune(start.Buffer(), start.Offset(), end.Offset())
+		})
+		buf.ConnectChanged(func() {
+			index.deleteMarks()
+		})
+
+		coreglib.WeakRefObject(buf, func() {
+			linkIndexMu.Lock()
+			delete(linkIndexes, key)
+			linkIndexMu.Unlock()
+		})
+	}
+
+	return index
+}
+
+// search returns the number of links that start at or before the given offset.
+func (idx *linkIndex) search(buf *gtk.TextBuffer, offset int) int {
+	return sort.Search(len(idx.links), func(i int) bool {
+		return buf.IterAtMark(idx.links[i].start).Offset() > offset
+	})
+}
+
+// at returns the link that contains the character at the given offset.
+func (idx *linkIndex) at(buf *gtk.TextBuffer, offset int) (EmbeddedURL, bool) {
+	i := idx.search(buf, offset) - 1
+	if i < 0 {
+		return EmbeddedURL{}, false
+	}
+
+	u := idx.links[i].embeddedURL(buf)
+	return u, offset < u.To
+}
+
+// next returns the first link that starts at or after the given offset and
+// isn't concealed. Adjacent links share the same tag, so they can only be told
+// apart using the index.
+func (idx *linkIndex) next(buf *gtk.TextBuffer, offset int) (EmbeddedURL, bool) {
+	for i := idx.search(buf, offset-1); i < len(idx.links); i++ {
+		u := idx.links[i].embeddedURL(buf)
+		if u.From < u.To && !IsConcealed(buf.IterAtOffset(u.From)) {
+			return u, true
+		}
+	}
+	return EmbeddedURL{}, false
+}
+
+// previous returns the last link that starts before the given offset and
+// isn't concealed.
+func (idx *linkIndex) previous(buf *gtk.TextBuffer, offset int) (EmbeddedURL, bool) {
+	for i := idx.search(buf, offset-1) - 1; i >= 0; i-- {
+		u := idx.links[i].embeddedURL(buf)
+		if u.From < u.To && !IsConcealed(buf.IterAtOffset(u.From)) {
+			return u, true
+		}
+	}
+	return EmbeddedURL{}, false
+}
+
+// prune removes the links that are entirely within the given range, which is
+// about to be deleted.
+func (idx *linkIndex) prune(buf *gtk.TextBuffer, start, end int) {
+	// Links before i start before the range.
+	i := idx.search(buf, start-1)
+	n := i
+	for n < len(idx.links) && buf.IterAtMark(idx.links[n].start).Offset() <= end {
+		n++
+	}
+
+	kept := i
+	for _, link := range idx.links[i:n] {
+		if buf.IterAtMark(link.end).Offset() <= end {
+			idx.dead = append(idx.dead, link.start, link.end)
+			continue
+		}
+		idx.links[kept] = link
+		kept++
+	}
+
+	if kept == n {
+		return
+	}
+
+	m := copy(idx.links[kept:], idx.links[n:])
+	clear(idx.links[kept+m:])
+	idx.links = idx.links[:kept+m]
+}
+
+// deleteMarks deletes the marks of the links removed by prune. This can't be
+// done while the range is being deleted, since deleting marks invalidates the
+// iterators of the deletion.
+func (idx *linkIndex) deleteMarks() {
+	for _, mark := range idx.dead {
+		if !mark.Deleted() {
+			mark.Buffer().DeleteMark(mark)
+		}
+	}
+	clear(idx.dead)
+	idx.dead = idx.dead[:0]
+}
+
+// AddLink marks the text between start and end in the given buffer as a link
+// to url. Links are applied a single tag that is shared by all links, while
+// their URLs and bounds are kept in a per-buffer index. The handler bound by
+// BindLinkHandler looks up links from this index.
+func AddLink(buf *gtk.TextBuffer, start, end *gtk.TextIter, url string) {
+	link := indexedLink{
+		// Text inserted at either edge of the link is not part of it.
+		start: buf.CreateMark("", start, false),
+		end:   buf.CreateMark("", end, true),
+		url:   url,
+	}
+
+	index := bufferLinkIndex(buf, true)
+	i := index.search(buf, start.Offset())
+	index.links = append(index.links, indexedLink{})
+	copy(index.links[i+1:], index.links[i:])
+	index.links[i] = link
+
+	buf.ApplyTag(linkTag(buf.TagTable()), start, end)
+}
+
+// linkTag returns the tag that is shared by all links in the given table.
+func linkTag(table *gtk.TextTagTable) *gtk.TextTag {
+	return Tags.FromTable(table, linkTagName)
+}
+
+// linkAtIter returns the link added using AddLink at the given iterator.
+func linkAtIter(it *gtk.TextIter) (EmbeddedURL, bool) {
+	buf := it.Buffer()
+
+	// Checking the tag is much cheaper than searching the index, and most
+	// of the text isn't a link.
+	tag := buf.TagTable().Lookup(linkTagName)
+	if tag == nil || !it.HasTag(tag) {
+		return EmbeddedURL{}, false
+	}
+
+	index := bufferLinkIndex(buf, false)
+	if index == nil {
+		return EmbeddedURL{}, false
+	}
+
+	return index.at(buf, it.Offset())
+}
@@ -0,0 +1,95 @@
+package md
+
+import (
+	"testing"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+func TestAddLink(t *testing.T) {
+	if !gtk.InitCheck() {
+		t.Skip("cannot initialize GTK")
+	}
+
+	buf := gtk.NewTextBuffer(nil)
+	buf.SetText("see a and b here")
+
+	// Add the links out of order to check that the index stays sorted.
+	AddLink(buf, buf.IterAtOffset(10), buf.IterAtOffset(11), "https://b.example")
+	AddLink(buf, buf.IterAtOffset(4), buf.IterAtOffset(5), "https://a.example")
+
+	tests := []struct {
+		offset int
+		url    string
+	}{
+		{0, ""},
+		{4, "https://a.example"},
+		{5, ""},
+		{10, "https://b.example"},
+		{11, ""},
+	}
+
+	for _, test := range tests {
+		var url string
+		if u := urlAtIter(buf.IterAtOffset(test.offset)); u != nil {
+			url = u.URL
+		}
+		if url != test.url {
+			t.Errorf("link at %d = %q, want %q", test.offset, url, test.url)
+		}
+	}
+
+	// Links move with the text around them.
+	buf.Insert(buf.StartIter(), "> ")
+
+	u := urlAtIter(buf.IterAtOffset(6))
+	if u == nil || *u != (EmbeddedURL{6, 7, "https://a.example"}) {
+		t.Errorf("link did not move with the text: %+v", u)
+	}
+
+	if u := nextLink(buf, 7); u == nil || u.URL != "https://b.example" {
+		t.Errorf("unexpected next link: %+v", u)
+	}
+	if u := previousLink(buf, 12); u == nil || u.URL != "https://a.example" {
+		t.Errorf("unexpected previous link: %+v", u)
+	}
+}
+
+func TestLinkIndexPrune(t *testing.T) {
+	if !gtk.InitCheck() {
+		t.Skip("cannot initialize GTK")
+	}
+
+	buf := gtk.NewTextBuffer(nil)
+	buf.SetText("see a and b here")
+	AddLink(buf, buf.IterAtOffset(4), buf.IterAtOffset(5), "https://a.example")
+	AddLink(buf, buf.IterAtOffset(10), buf.IterAtOffset(11), "https://b.example")
+
+	index := bufferLinkIndex(buf, false)
+	removed := index.links[0]
+
+	// Deleting the text of a link removes it, and the link that moves into
+	// its place is still found.
+	buf.Delete(buf.IterAtOffset(4), buf.IterAtOffset(10))
+
+	if len(index.links) != 1 {
+		t.Fatalf("index has %d links, want 1", len(index.links))
+	}
+	if !removed.start.Deleted() || !removed.end.Deleted() {
+		t.Error("marks of the removed link are not deleted")
+	}
+	if u := urlAtIter(buf.IterAtOffset(4)); u == nil || *u != (EmbeddedURL{4, 5, "https://b.example"}) {
+		t.Errorf("unexpected link after deleting: %+v", u)
+	}
+
+	// Deleting part of a link keeps it.
+	AddLink(buf, buf.IterAtOffset(6), buf.IterAtOffset(10), "https://c.example")
+	buf.Delete(buf.IterAtOffset(6), buf.IterAtOffset(8))
+
+	if len(index.links) != 2 {
+		t.Fatalf("index has %d links, want 2", len(index.links))
+	}
+	if u := urlAtIter(buf.IterAtOffset(6)); u == nil || *u != (EmbeddedURL{6, 8, "https://c.example"}) {
+		t.Errorf("unexpected link after deleting: %+v", u)
+	}
+}
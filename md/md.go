@@ -2,56 +2,88 @@
 package md
 
 import (
-	"unicode"
-
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/diamondburned/gotk4/pkg/pango"
 	"github.com/diamondburned/gotkit/gtkutil/textutil"
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/renderer"
 	"github.com/yuin/goldmark/renderer/html"
 	"github.com/yuin/goldmark/text"
 	markutil "github.com/yuin/goldmark/util"
-	"libdb.so/go-emoji"
 )
 
 // Parser is the default Markdown parser.
 var Parser = parser.NewParser(
 	parser.WithInlineParsers(
-		markutil.Prioritized(parser.NewLinkParser(), 0),
-		markutil.Prioritized(parser.NewAutoLinkParser(), 1),
-		markutil.Prioritized(parser.NewEmphasisParser(), 2),
-		markutil.Prioritized(parser.NewCodeSpanParser(), 3),
-		markutil.Prioritized(parser.NewRawHTMLParser(), 4),
+		// TaskCheckBox must take precedence over the link parser.
+		markutil.Prioritized(extension.NewTaskCheckBoxParser(), 0),
+		markutil.Prioritized(parser.NewLinkParser(), 1),
+		markutil.Prioritized(parser.NewAutoLinkParser(), 2),
+		markutil.Prioritized(parser.NewEmphasisParser(), 3),
+		markutil.Prioritized(parser.NewCodeSpanParser(), 4),
+		markutil.Prioritized(parser.NewRawHTMLParser(), 5),
+		markutil.Prioritized(extension.NewStrikethroughParser(), 6),
+		markutil.Prioritized(NewSpoilerParser(), 7),
+		markutil.Prioritized(NewEmojiParser(), 8),
+		// Linkify must come last, so that it doesn't take over the emphasis
+		// and strikethrough delimiters that it also triggers on.
+		markutil.Prioritized(NewLinkifyParser(LinkifyOpts{WWW: true}), 9),
 	),
 	parser.WithBlockParsers(
 		markutil.Prioritized(parser.NewParagraphParser(), 0),
 		markutil.Prioritized(parser.NewBlockquoteParser(), 1),
 		markutil.Prioritized(parser.NewATXHeadingParser(), 2),
-		markutil.Prioritized(parser.NewFencedCodeBlockParser(), 3),
-		markutil.Prioritized(parser.NewThematicBreakParser(), 4), // <hr>
+		// Setext headings must take precedence over thematic breaks and
+		// lists, since they all start with "-".
+		markutil.Prioritized(parser.NewSetextHeadingParser(), 3),
+		markutil.Prioritized(parser.NewFencedCodeBlockParser(), 4),
+		markutil.Prioritized(parser.NewThematicBreakParser(), 5), // <hr>
+		markutil.Prioritized(parser.NewListParser(), 6),
+		markutil.Prioritized(parser.NewListItemParser(), 7),
+		markutil.Prioritized(parser.NewCodeBlockParser(), 8),
+		markutil.Prioritized(parser.NewHTMLBlockParser(), 9),
+	),
+	parser.WithParagraphTransformers(
+		markutil.Prioritized(extension.NewTableParagraphTransformer(), 0),
+	),
+	parser.WithASTTransformers(
+		markutil.Prioritized(extension.NewTableASTTransformer(), 0),
 	),
 )
 
-// Renderer is the default Markdown renderer.
+// Renderer is the default Markdown renderer. Raw HTML is omitted by this
+// renderer; use Converter to sanitize it instead.
 var Renderer = html.NewRenderer(
 	html.WithHardWraps(),
-	html.WithUnsafe(),
 )
 
-// Converter is the default converter that outputs HTML.
-var Converter = goldmark.New(
-	goldmark.WithParser(Parser),
-	goldmark.WithRenderer(
-		renderer.NewRenderer(
-			renderer.WithNodeRenderers(
-				markutil.Prioritized(Renderer, 1000),
+// Converter is the default converter that outputs HTML. Raw HTML, links and
+// images are sanitized using MatrixHTMLPolicy.
+var Converter = NewConverter(&MatrixHTMLPolicy)
+
+// NewConverter creates a new converter that outputs HTML sanitized using the
+// given policy.
+func NewConverter(policy *HTMLPolicy) goldmark.Markdown {
+	return goldmark.New(
+		goldmark.WithParser(Parser),
+		goldmark.WithRenderer(
+			renderer.NewRenderer(
+				renderer.WithNodeRenderers(
+					markutil.Prioritized(Renderer, 1000),
+					markutil.Prioritized(extension.NewTaskCheckBoxHTMLRenderer(), 500),
+					markutil.Prioritized(extension.NewTableHTMLRenderer(), 500),
+					markutil.Prioritized(extension.NewStrikethroughHTMLRenderer(), 500),
+					markutil.Prioritized(NewSpoilerHTMLRenderer(), 500),
+					markutil.Prioritized(NewEmojiHTMLRenderer(), 500),
+					markutil.Prioritized(NewSanitizingHTMLRenderer(policy), 100),
+				),
 			),
 		),
-	),
-)
+	)
+}
 
 // EmojiScale is the scale of Unicode emojis.
 const EmojiScale = 2.5
@@ -124,13 +156,16 @@ var whitespaces = [255]bool{
 	'\r': true,
 }
 
-// IsUnicodeEmoji returns true if the given string only contains a Unicode
-// emoji.
+// IsUnicodeEmoji returns true if the given string only contains Unicode
+// emojis and whitespaces. Emojis are matched as whole sequences, so ZWJ
+// sequences, flags, keycaps and emojis with skin tone modifiers are all
+// recognized. An empty string or a string of only whitespaces also returns
+// true.
+//
+// Unlike before emojis were matched as sequences, characters that are shown
+// as text by default, such as "©" and "™", are only emojis if they're followed
+// by the emoji variation selector U+FE0F.
 func IsUnicodeEmoji(v string) bool {
-	for _, r := range v {
-		if !unicode.IsSpace(r) && !emoji.IsEmoji(r) {
-			return false
-		}
-	}
-	return true
+	_, ok := CountEmojis(v)
+	return ok
 }
@@ -0,0 +1,42 @@
+package md
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestConverterStrikethrough(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{
+			name: "strikethrough",
+			in:   "~~gone~~ here",
+			out:  "<p><del>gone</del> here</p>\n",
+		},
+		{
+			name: "nested",
+			in:   "~~**gone**~~",
+			out:  "<p><del><strong>gone</strong></del></p>\n",
+		},
+		{
+			name: "code span",
+			in:   "`~~code~~`",
+			out:  "<p><code>~~code~~</code></p>\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := Converter.Convert([]byte(test.in), &out); err != nil {
+				t.Fatal("cannot convert:", err)
+			}
+			if out.String() != test.out {
+				t.Errorf("unexpected output:\n got %q\nwant %q", out.String(), test.out)
+			}
+		})
+	}
+}
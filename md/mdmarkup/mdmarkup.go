@@ -0,0 +1,382 @@
+// Package mdmarkup renders Markdown ASTs into Pango markup for use in
+// gtk.Labels. The text attributes are taken from md.Tags, so the output looks
+// consistent with mdrender. Block elements are degraded to their inline
+// equivalents.
+package mdmarkup
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/diamondburned/chatkit/md"
+	"github.com/diamondburned/gotk4/pkg/pango"
+	"github.com/diamondburned/gotkit/gtkutil/textutil"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+
+	extast "github.com/yuin/goldmark/extension/ast"
+)
+
+// SpoilerText is the text that spoilers are replaced with. Labels cannot
+// reveal spoilers, so their content is never rendered.
+var SpoilerText = "spoiler"
+
+// RenderString parses src using md.Parser and renders it.
+func RenderString(src string) string {
+	b := []byte(src)
+	return Render(b, md.Parser.Parse(text.NewReader(b)))
+}
+
+// Render renders the AST n of the given source into Pango markup.
+func Render(src []byte, n ast.Node) string {
+	r := renderer{src: src}
+	r.render(n)
+	return strings.TrimSpace(r.out.String())
+}
+
+type renderer struct {
+	out strings.Builder
+	src []byte
+}
+
+// sub renders the children of n into a new string.
+func (r *renderer) sub(n ast.Node) string {
+	sub := renderer{src: r.src}
+	sub.renderChildren(n)
+	return strings.TrimRight(sub.out.String(), "\n")
+}
+
+// endLine ensures that the output ends with a new line.
+func (r *renderer) endLine() {
+	s := r.out.String()
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		r.out.WriteByte('\n')
+	}
+}
+
+func (r *renderer) renderChildren(n ast.Node) {
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		r.render(c)
+	}
+}
+
+// renderChildrenWithTag renders n's children inside a span with the
+// attributes of the md.Tags tag with the given name.
+func (r *renderer) renderChildrenWithTag(n ast.Node, tagName string) {
+	r.out.WriteString(openSpan(md.Tags[tagName]))
+	r.renderChildren(n)
+	r.out.WriteString("</span>")
+}
+
+// writeWithTag writes the escaped text inside a span with the attributes of
+// the md.Tags tag with the given name.
+func (r *renderer) writeWithTag(text, tagName string) {
+	r.out.WriteString(openSpan(md.Tags[tagName]))
+	r.out.WriteString(html.EscapeString(text))
+	r.out.WriteString("</span>")
+}
+
+func (r *renderer) render(n ast.Node) {
+	switch n := n.(type) {
+	case *ast.Text:
+		r.out.WriteString(html.EscapeString(string(n.Segment.Value(r.src))))
+		if n.HardLineBreak() || n.SoftLineBreak() {
+			r.out.WriteByte('\n')
+		}
+
+	case *ast.String:
+		r.out.WriteString(html.EscapeString(string(n.Value)))
+
+	case *ast.Emphasis:
+		switch n.Level {
+		case 1:
+			r.renderChildrenWithTag(n, "i")
+		case 2:
+			r.renderChildrenWithTag(n, "b")
+		default:
+			r.renderChildren(n)
+		}
+
+	case *extast.Strikethrough:
+		r.renderChildrenWithTag(n, "del")
+
+	case *ast.CodeSpan:
+		r.renderChildrenWithTag(n, "code")
+
+	case *md.Spoiler:
+		r.writeWithTag(SpoilerText, "spoiler")
+
+	case *md.Emoji:
+		r.out.WriteString(html.EscapeString(n.Unicode))
+
+	case *ast.Link:
+		r.writeLink(string(n.Destination), r.sub(n))
+
+	case *ast.AutoLink:
+		r.writeLink(md.AutoLinkURL(n, r.src), html.EscapeString(string(n.Label(r.src))))
+
+	case *ast.Image:
+		r.out.WriteString(html.EscapeString(string(n.Text(r.src))))
+
+	case *ast.RawHTML, *ast.HTMLBlock:
+		// Not representable.
+
+	case *ast.Heading:
+		r.endLine()
+		if n.Level >= 1 && n.Level <= 6 {
+			r.renderChildrenWithTag(n, "h"+strconv.Itoa(n.Level))
+		} else {
+			r.renderChildren(n)
+		}
+		r.endLine()
+
+	case *ast.FencedCodeBlock, *ast.CodeBlock:
+		var code strings.Builder
+		lines := n.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			line := lines.At(i)
+			code.Write(line.Value(r.src))
+		}
+
+		r.endLine()
+		r.writeWithTag(strings.TrimRight(code.String(), "\n"), "code")
+		r.out.WriteByte('\n')
+
+	case *ast.ThematicBreak:
+		r.endLine()
+
+	case *ast.Paragraph, *ast.TextBlock:
+		r.endLine()
+		r.renderChildren(n)
+		r.endLine()
+
+	case *ast.Blockquote:
+		r.endLine()
+		r.out.WriteString(openSpan(md.Tags["blockquote"]))
+		r.out.WriteString(prefixLines(r.sub(n), "&gt; ", "&gt; "))
+		r.out.WriteString("</span>\n")
+
+	case *ast.List:
+		r.endLine()
+		index := n.Start
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			bullet := "•"
+			if n.IsOrdered() {
+				bullet = strconv.Itoa(index) + "."
+				index++
+			}
+
+			indent := strings.Repeat(" ", utf8.RuneCountInString(bullet)+1)
+			first := openSpan(md.Tags["listmarker"]) + bullet + "</span> "
+			r.out.WriteString(prefixLines(r.sub(c), first, indent))
+			r.out.WriteByte('\n')
+		}
+
+	case *extast.TaskCheckBox:
+		if n.IsChecked {
+			r.writeWithTag("[x]", "listmarker")
+		} else {
+			r.writeWithTag("[ ]", "listmarker")
+		}
+		r.out.WriteByte(' ')
+
+	case *extast.Table:
+		r.endLine()
+		r.renderChildren(n)
+
+	case *extast.TableHeader, *extast.TableRow:
+		cells := make([]string, 0, n.ChildCount())
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			cells = append(cells, r.sub(c))
+		}
+
+		row := strings.Join(cells, " | ")
+		if _, ok := n.(*extast.TableHeader); ok {
+			row = openSpan(md.Tags["b"]) + row + "</span>"
+		}
+
+		r.out.WriteString(row)
+		r.out.WriteByte('\n')
+
+	default:
+		r.renderChildren(n)
+	}
+}
+
+// writeLink writes a link with the given URL around the given markup. Only
+// URLs with one of md.LinkSchemes are linked; the markup is written
+// as-is for other URLs.
+func (r *renderer) writeLink(url, markup string) {
+	if !md.IsLinkURL(url) {
+		r.out.WriteString(markup)
+		return
+	}
+
+	r.out.WriteString(`<a href="`)
+	r.out.WriteString(html.EscapeString(url))
+	r.out.WriteString(`">`)
+	r.out.WriteString(markup)
+	r.out.WriteString("</a>")
+}
+
+// prefixLines prefixes the first line of s with first and all other lines
+// with rest.
+func prefixLines(s, first, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if i == 0 {
+			lines[i] = first + line
+		} else {
+			lines[i] = rest + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// openSpan returns the opening span tag with the attributes of the given text
+// tag.
+func openSpan(tag textutil.TextTag) string {
+	return "<span" + SpanAttributes(tag) + ">"
+}
+
+// SpanAttributes converts the properties of the given text tag into Pango
+// markup span attributes. Each attribute is prefixed with a space. Properties
+// that have no span attribute equivalent, such as margins, are ignored.
+func SpanAttributes(tag textutil.TextTag) string {
+	keys := make([]string, 0, len(tag))
+	for k := range tag {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		name, value, ok := spanAttribute(k, tag[k])
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&b, ` %s="%s"`, name, html.EscapeString(value))
+	}
+
+	return b.String()
+}
+
+func spanAttribute(key string, value interface{}) (string, string, bool) {
+	switch key {
+	case "family":
+		if v, ok := value.(string); ok {
+			return "font_family", v, true
+		}
+	case "foreground", "background":
+		if v, ok := value.(string); ok {
+			if color, ok := pangoColor(v); ok {
+				return key, color, true
+			}
+		}
+	case "style":
+		switch value {
+		case pango.StyleNormal:
+			return "style", "normal", true
+		case pango.StyleItalic:
+			return "style", "italic", true
+		case pango.StyleOblique:
+			return "style", "oblique", true
+		}
+	case "weight":
+		if v, ok := value.(pango.Weight); ok {
+			return "weight", strconv.Itoa(int(v)), true
+		}
+	case "underline":
+		switch value {
+		case pango.UnderlineNone:
+			return "underline", "none", true
+		case pango.UnderlineSingle:
+			return "underline", "single", true
+		case pango.UnderlineDouble:
+			return "underline", "double", true
+		case pango.UnderlineLow:
+			return "underline", "low", true
+		case pango.UnderlineError:
+			return "underline", "error", true
+		}
+	case "strikethrough", "insert-hyphens":
+		if v, ok := value.(bool); ok {
+			return strings.ReplaceAll(key, "-", "_"), strconv.FormatBool(v), true
+		}
+	case "rise":
+		if v, ok := value.(int); ok {
+			return "rise", strconv.Itoa(v), true
+		}
+	case "scale":
+		if v, ok := value.(float64); ok {
+			return "size", strconv.Itoa(int(v*100)) + "%", true
+		}
+	}
+	return "", "", false
+}
+
+// pangoColor converts a color of a text tag into the #rrggbbaa form that Pango
+// markup accepts. Text tags accept CSS colors such as rgba(), which Pango
+// markup can't parse. False is returned if the color can't be converted.
+func pangoColor(color string) (string, bool) {
+	color = strings.TrimSpace(color)
+
+	if r, g, b, a, ok := parseRGBFunc(color); ok {
+		return fmt.Sprintf("#%02x%02x%02x%02x", r, g, b, a), true
+	}
+
+	c := pango.NewColor(0, 0, 0)
+	alpha, ok := c.ParseWithAlpha(color)
+	if !ok {
+		return "", false
+	}
+
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.Red()>>8, c.Green()>>8, c.Blue()>>8, alpha>>8), true
+}
+
+// parseRGBFunc parses a CSS rgb() or rgba() color with integer channels and
+// an alpha between 0 and 1.
+func parseRGBFunc(color string) (r, g, b, a uint8, ok bool) {
+	args, ok := strings.CutPrefix(color, "rgba(")
+	if !ok {
+		args, ok = strings.CutPrefix(color, "rgb(")
+	}
+	if !ok {
+		return 0, 0, 0, 0, false
+	}
+
+	args, ok = strings.CutSuffix(args, ")")
+	if !ok {
+		return 0, 0, 0, 0, false
+	}
+
+	parts := strings.Split(args, ",")
+	if len(parts) != 3 && len(parts) != 4 {
+		return 0, 0, 0, 0, false
+	}
+
+	var channels [3]uint8
+	for i := range channels {
+		v, err := strconv.ParseUint(strings.TrimSpace(parts[i]), 10, 8)
+		if err != nil {
+			return 0, 0, 0, 0, false
+		}
+		channels[i] = uint8(v)
+	}
+
+	a = 0xFF
+	if len(parts) == 4 {
+		v, err := strconv.ParseFloat(strings.TrimSpace(parts[3]), 64)
+		if err != nil || v < 0 || v > 1 {
+			return 0, 0, 0, 0, false
+		}
+		a = uint8(math.Round(v * 0xFF))
+	}
+
+	return channels[0], channels[1], channels[2], a, true
+}
@@ -0,0 +1,104 @@
+package mdmarkup
+
+import (
+	"testing"
+
+	"github.com/diamondburned/gotk4/pkg/pango"
+	"github.com/diamondburned/gotkit/gtkutil/textutil"
+)
+
+const (
+	listMarker = `<span foreground="#808080ff" weight="700">`
+	code       = `<span font_family="Monospace" insert_hyphens="false">`
+)
+
+func TestRenderString(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{
+			name: "spoiler",
+			in:   "the ending is ||secret||",
+			out:  `the ending is <span background="#80808040">spoiler</span>`,
+		},
+		{
+			name: "links",
+			in:   "[docs](https://example.com) [bad](javascript:alert(1)) <file:///etc/passwd>",
+			out:  `<a href="https://example.com">docs</a> bad file:///etc/passwd`,
+		},
+		{
+			name: "ordered list",
+			in:   "1. a\n2. b",
+			out:  listMarker + "1.</span> a\n" + listMarker + "2.</span> b",
+		},
+		{
+			name: "nested list",
+			in:   "- a\n  - b",
+			out:  listMarker + "•</span> a\n  " + listMarker + "•</span> b",
+		},
+		{
+			name: "table",
+			in:   "| a | b |\n|---|---|\n| 1 | 2 |",
+			out:  "<span weight=\"700\">a | b</span>\n1 | 2",
+		},
+		{
+			name: "escaping",
+			in:   "a < b & c > d `<code>` [q](https://example.com/?a=1&b=2)",
+			out: "a &lt; b &amp; c &gt; d " + code + "&lt;code&gt;</span> " +
+				`<a href="https://example.com/?a=1&amp;b=2">q</a>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := RenderString(test.in)
+			if out != test.out {
+				t.Errorf("unexpected output:\n got %q\nwant %q", out, test.out)
+			}
+			if _, _, _, err := pango.ParseMarkup(out, -1, 0); err != nil {
+				t.Errorf("output is not valid Pango markup: %v", err)
+			}
+		})
+	}
+}
+
+func TestPangoColor(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+		ok  bool
+	}{
+		{"rgba(128, 128, 128, 0.25)", "#80808040", true},
+		{"rgb(255,0,0)", "#ff0000ff", true},
+		{"#fff", "#ffffffff", true},
+		{"#789922", "#789922ff", true},
+		{"#80808040", "#80808040", true},
+		{"red", "#ff0000ff", true},
+		{"rgba(1, 2, 3, 2)", "", false},
+		{"rgb(256, 0, 0)", "", false},
+		{"url(x)", "", false},
+		{"", "", false},
+	}
+
+	for _, test := range tests {
+		out, ok := pangoColor(test.in)
+		if out != test.out || ok != test.ok {
+			t.Errorf("pangoColor(%q) = (%q, %v), want (%q, %v)", test.in, out, ok, test.out, test.ok)
+		}
+	}
+}
+
+func TestSpanAttributes(t *testing.T) {
+	attrs := SpanAttributes(textutil.TextTag{
+		"foreground":  "not a color",
+		"background":  "rgba(0, 0, 0, 0)",
+		"weight":      pango.WeightBold,
+		"left-margin": 12,
+	})
+
+	if want := ` background="#00000000" weight="700"`; attrs != want {
+		t.Errorf("unexpected attributes:\n got %q\nwant %q", attrs, want)
+	}
+}
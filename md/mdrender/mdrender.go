@@ -6,10 +6,23 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/diamondburned/chatkit/components/embed"
+	"github.com/diamondburned/chatkit/md"
 	"github.com/diamondburned/chatkit/md/block"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/gtkutil/imgutil"
 	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/text"
 	"libdb.so/ctxt"
+
+	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
+	extast "github.com/yuin/goldmark/extension/ast"
+)
+
+// Default maximum sizes of inline images.
+const (
+	DefaultImageMaxWidth  = 400
+	DefaultImageMaxHeight = 300
 )
 
 // RendererFunc is a map of callbacks for handling each ast.Node.
@@ -37,6 +50,30 @@ func WithFallbackRenderer(renderer RendererFunc) OptionFunc {
 	}
 }
 
+// WithImageProvider sets the provider used to fetch inline images. By default,
+// imgutil.HTTPProvider is used.
+func WithImageProvider(provider imgutil.Provider) OptionFunc {
+	return func(r *Renderer) {
+		r.imageProvider = provider
+	}
+}
+
+// WithImageMaxSize sets the maximum size of inline images. Larger images are
+// scaled down to fit.
+func WithImageMaxSize(w, h int) OptionFunc {
+	return func(r *Renderer) {
+		r.imageMaxSize = [2]int{w, h}
+	}
+}
+
+// WithJumboEmojis makes MarkdownViewer enlarge messages that only contain at
+// most max emojis. See block.Viewer.EnlargeEmojis.
+func WithJumboEmojis(max int) OptionFunc {
+	return func(r *Renderer) {
+		r.jumboEmojis = max
+	}
+}
+
 // WithState adds a new container state to the given context.
 func WithState(ctx context.Context, state *block.ContainerState) context.Context {
 	return ctxt.With(ctx, state)
@@ -48,13 +85,18 @@ type Renderer struct {
 	renderers map[ast.NodeKind]RendererFunc
 	fallbackR RendererFunc
 	src       []byte
+
+	imageProvider imgutil.Provider
+	imageMaxSize  [2]int
+	jumboEmojis   int
 }
 
 // NewRenderer creates a new renderer.
 func NewRenderer(src []byte, state *block.ContainerState, opts ...OptionFunc) *Renderer {
 	r := Renderer{
-		src:   src,
-		state: state,
+		src:          src,
+		state:        state,
+		imageMaxSize: [2]int{DefaultImageMaxWidth, DefaultImageMaxHeight},
 	}
 
 	if len(opts) > 0 {
@@ -141,13 +183,14 @@ func (r *Renderer) RenderOnce(ctx context.Context, n ast.Node) ast.WalkStatus {
 		text := r.State(ctx).TextBlock()
 		text.Insert(string(n.Segment.Value(r.src)))
 
-		switch {
-		case n.HardLineBreak():
-			text.InsertNewLines(2)
-		case n.SoftLineBreak():
+		if n.HardLineBreak() || n.SoftLineBreak() {
 			text.InsertNewLines(1)
 		}
 
+	case *ast.Document, *ast.TextBlock:
+		// Only render the children.
+		return ast.WalkContinue
+
 	case *ast.Emphasis:
 		var tagName string
 		switch n.Level {
@@ -161,6 +204,28 @@ func (r *Renderer) RenderOnce(ctx context.Context, n ast.Node) ast.WalkStatus {
 
 		return r.RenderChildrenWithTag(ctx, n, tagName)
 
+	case *extast.Strikethrough:
+		return r.RenderChildrenWithTag(ctx, n, "del")
+
+	case *md.Spoiler:
+		text := r.State(ctx).TextBlock()
+		text.ConnectSpoilerHandler()
+
+		startIx := text.Iter.Offset()
+		status := r.RenderChildren(ctx, n)
+
+		start := text.Iter.Copy()
+		start.SetOffset(startIx)
+
+		// Get the tag only after rendering, so it's above all tags that the
+		// children may have added.
+		md.AddSpoiler(text.Buffer, start, text.Iter)
+		return status
+
+	case *md.Emoji:
+		text := r.State(ctx).TextBlock()
+		text.Insert(n.Unicode)
+
 	case *ast.Heading:
 		// h1 ~ h6
 		if n.Level >= 1 && n.Level <= 6 {
@@ -195,27 +260,37 @@ func (r *Renderer) RenderOnce(ctx context.Context, n ast.Node) ast.WalkStatus {
 		text.ConnectLinkHandler()
 
 		startIx := text.Iter.Offset()
-		text.Insert(string(n.URL(r.src)))
+		text.Insert(string(n.Label(r.src)))
 
 		start := text.Iter.Copy()
 		start.SetOffset(startIx)
 		end := text.Iter
 
-		text.ApplyLink(string(n.URL(r.src)), start, end)
+		text.ApplyLink(md.AutoLinkURL(n, r.src), start, end)
 		return ast.WalkContinue
 
+	case *ast.Image:
+		if len(n.Destination) == 0 {
+			// Nothing to load, so just show the alt text.
+			return ast.WalkContinue
+		}
+
+		r.insertImage(ctx, string(n.Destination), string(n.Text(r.src)))
+		return ast.WalkSkipChildren
+
 	case *ast.List:
+		listIx := block.ListIndex{
+			Level:     1,
+			Unordered: !n.IsOrdered(),
+		}
 		if n.IsOrdered() {
-			ctx = ctxt.With(ctx, &block.ListIndex{
-				Index:     n.Start,
-				Unordered: false,
-			})
-		} else {
-			ctx = ctxt.With(ctx, &block.ListIndex{
-				Unordered: true,
-			})
+			listIx.Index = n.Start
 		}
-		r.RenderChildren(ctx, n)
+		if parent, ok := ctxt.From[*block.ListIndex](ctx); ok {
+			listIx.Level = parent.Level + 1
+		}
+
+		r.RenderChildren(ctxt.With(ctx, &listIx), n)
 		return ast.WalkSkipChildren
 
 	case *ast.ListItem:
@@ -228,7 +303,6 @@ func (r *Renderer) RenderOnce(ctx context.Context, n ast.Node) ast.WalkStatus {
 			return ast.WalkContinue
 		}
 
-		listIx.Level = n.Offset
 		slog.Debug(
 			"rendering list item using mdrender",
 			"index", listIx.Index,
@@ -237,13 +311,44 @@ func (r *Renderer) RenderOnce(ctx context.Context, n ast.Node) ast.WalkStatus {
 
 		listItem := block.NewListItemBlock(r.State(ctx), *listIx)
 		r.State(ctx).Append(listItem)
-
-		// TODO: prevent children from having block-level elements.
-		r.RenderChildren(ctx, n)
+		r.RenderChildren(ctxt.With(WithState(ctx, listItem.State()), listItem), n)
 
 		listIx.Index++
 		return ast.WalkSkipChildren
 
+	case *extast.TaskCheckBox:
+		// The checkbox is always the first child of the list item's text.
+		if listItem, ok := ctxt.From[*block.ListItemBlock](ctx); ok {
+			listItem.SetTask(n.IsChecked)
+		}
+
+	case *extast.Table:
+		table := block.NewTableBlock(r.State(ctx))
+		r.State(ctx).Append(table)
+		r.State(ctx).FinalizeBlock() // no more text inside the table
+
+		r.RenderChildren(ctxt.With(ctx, table), n)
+		return ast.WalkSkipChildren
+
+	case *extast.TableHeader:
+		if table, ok := ctxt.From[*block.TableBlock](ctx); ok {
+			table.AddRow(true)
+		}
+
+	case *extast.TableRow:
+		if table, ok := ctxt.From[*block.TableBlock](ctx); ok {
+			table.AddRow(false)
+		}
+
+	case *extast.TableCell:
+		table, ok := ctxt.From[*block.TableBlock](ctx)
+		if !ok {
+			return ast.WalkContinue
+		}
+
+		cell := table.AddCell(tableJustification(n.Alignment))
+		return r.RenderChildren(WithState(ctx, cell), n)
+
 	case *ast.Paragraph:
 		// Fix stupid assumptions about HTML.
 		if n.ChildCount() == 1 {
@@ -255,23 +360,60 @@ func (r *Renderer) RenderOnce(ctx context.Context, n ast.Node) ast.WalkStatus {
 		text := r.State(ctx).TextBlock()
 		text.EndLine(2)
 
-	case *ast.FencedCodeBlock:
+	case *ast.FencedCodeBlock, *ast.CodeBlock:
 		lines := n.Lines()
 		len := lines.Len()
 		if len == 0 {
 			return ast.WalkContinue
 		}
 
+		var language string
+		if fenced, ok := n.(*ast.FencedCodeBlock); ok {
+			language = string(fenced.Language(r.src))
+		}
+
 		code := block.NewCodeBlock(r.State(ctx))
 		code.TextBlock().TagNameBounded("code", func() {
 			r.InsertSegments(code.TextBlock(), lines)
 		})
-		code.Highlight(string(n.Language(r.src)))
+		code.Highlight(language)
 
 		r.State(ctx).Append(code)
 		r.State(ctx).FinalizeBlock() // no more code from here on
 		return ast.WalkSkipChildren
 
+	case *ast.ThematicBreak:
+		r.State(ctx).Append(block.NewSeparatorBlock())
+		r.State(ctx).FinalizeBlock()
+
+	case *ast.RawHTML:
+		// Markdown messages are never sent as HTML, so raw HTML is shown as it
+		// is written, the same as in the composer.
+		text := r.State(ctx).TextBlock()
+		text.TagNameBounded("htmltag", func() {
+			r.InsertSegments(text, n.Segments)
+		})
+
+	case *ast.HTMLBlock:
+		var html strings.Builder
+		lines := n.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			line := lines.At(i)
+			html.Write(line.Value(r.src))
+		}
+		if n.HasClosure() {
+			html.Write(n.ClosureLine.Value(r.src))
+		}
+
+		// Show HTML blocks as they are, the same way as inline HTML.
+		text := r.State(ctx).TextBlock()
+		text.EndLine(2)
+		text.TagNameBounded("htmltag", func() {
+			value := strings.ReplaceAll(html.String(), "\r\n", "\n")
+			text.Insert(strings.TrimRight(value, "\n"))
+		})
+		return ast.WalkSkipChildren
+
 	case *ast.Blockquote:
 		quote := block.NewBlockquote(r.State(ctx))
 		r.State(ctx).Append(quote)
@@ -286,6 +428,53 @@ func (r *Renderer) RenderOnce(ctx context.Context, n ast.Node) ast.WalkStatus {
 	return ast.WalkContinue
 }
 
+// insertImage inserts an inline image at the current text position. Clicking
+// the image opens it in an embed.Viewer.
+func (r *Renderer) insertImage(ctx context.Context, url, alt string) {
+	text := r.State(ctx).TextBlock()
+
+	opts := embed.Opts{
+		Type:     embed.EmbedTypeImage,
+		Provider: r.imageProvider,
+	}
+
+	image := embed.New(ctx, r.imageMaxSize[0], r.imageMaxSize[1], opts)
+	image.SetFromURL(url)
+	image.SetOpenURL(func() {
+		viewer, err := embed.NewViewer(ctx, url, opts)
+		if err != nil {
+			slog.Error(
+				"cannot open inline image in viewer",
+				"url", url,
+				"err", err)
+			return
+		}
+		viewer.Present()
+	})
+
+	if alt != "" {
+		image.SetTooltipText(alt)
+		image.UpdateProperty(
+			[]gtk.AccessibleProperty{gtk.AccessiblePropertyLabel},
+			[]coreglib.Value{*coreglib.NewValue(alt)},
+		)
+	}
+
+	anchor := text.Buffer.CreateChildAnchor(text.Iter)
+	md.InsertCustomImageWidget(text.TextView, anchor, image)
+}
+
+func tableJustification(align extast.Alignment) gtk.Justification {
+	switch align {
+	case extast.AlignCenter:
+		return gtk.JustifyCenter
+	case extast.AlignRight:
+		return gtk.JustifyRight
+	default:
+		return gtk.JustifyLeft
+	}
+}
+
 // InsertSegments inserts the given text segments into the buffer.
 func (r *Renderer) InsertSegments(text *block.TextBlock, segs *text.Segments) {
 	// Nothing about this "segments" API makes sense. It's literally useless
@@ -0,0 +1,201 @@
+package mdrender
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/diamondburned/chatkit/md"
+	"github.com/diamondburned/chatkit/md/block"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/gtkutil/imgutil"
+	"github.com/yuin/goldmark/text"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestRendererGolden(t *testing.T) {
+	if !gtk.InitCheck() {
+		t.Skip("cannot initialize GTK")
+	}
+
+	files, err := filepath.Glob(filepath.Join("testdata", "*.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".md")
+
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+
+			v := block.NewViewer(ctx)
+			NewRenderer(src, v.State()).Render(ctx, md.Parser.Parse(text.NewReader(src)))
+
+			var out strings.Builder
+			dumpState(&out, v.State(), 0)
+
+			goldenFile := strings.TrimSuffix(file, ".md") + ".golden"
+			if *update {
+				if err := os.WriteFile(goldenFile, []byte(out.String()), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			golden, err := os.ReadFile(goldenFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := out.String(); got != string(golden) {
+				t.Errorf("unexpected output:\n%s\nexpected:\n%s", got, golden)
+			}
+		})
+	}
+}
+
+func TestRendererStrikethrough(t *testing.T) {
+	if !gtk.InitCheck() {
+		t.Skip("cannot initialize GTK")
+	}
+
+	src := []byte("a ~~b~~ c")
+	ctx := context.Background()
+
+	v := block.NewViewer(ctx)
+	NewRenderer(src, v.State()).Render(ctx, md.Parser.Parse(text.NewReader(src)))
+
+	var text *block.TextBlock
+	v.State().ForEach(func(b block.WidgetBlock) bool {
+		text, _ = b.(*block.TextBlock)
+		return true
+	})
+
+	if got := textOf(text); got != "a b c" {
+		t.Fatalf("unexpected text %q", got)
+	}
+
+	del := text.Buffer.TagTable().Lookup("del")
+	if del == nil {
+		t.Fatal("del tag is not in the table")
+	}
+
+	for offset, want := range map[int]bool{0: false, 2: true, 4: false} {
+		if text.Buffer.IterAtOffset(offset).HasTag(del) != want {
+			t.Errorf("offset %d: expected struck through = %v", offset, want)
+		}
+	}
+}
+
+// nopProvider is an image provider that never loads anything, so tests don't
+// touch the network.
+type nopProvider struct{}
+
+func (nopProvider) Schemes() []string { return []string{"https", "http"} }
+
+func (nopProvider) Do(context.Context, *url.URL, imgutil.ImageSetter) {}
+
+func TestRendererImage(t *testing.T) {
+	if !gtk.InitCheck() {
+		t.Skip("cannot initialize GTK")
+	}
+
+	src := []byte("a ![cat](https://example.com/cat.png) b ![](https://example.com/dog.png) ![no image]()")
+	ctx := context.Background()
+
+	v := block.NewViewer(ctx)
+	NewRenderer(src, v.State(), WithImageProvider(nopProvider{}), WithImageMaxSize(20, 10)).
+		Render(ctx, md.Parser.Parse(text.NewReader(src)))
+
+	var out strings.Builder
+	dumpState(&out, v.State(), 0)
+
+	// Images are inserted as child anchors, and images without a destination
+	// are shown as their alt text.
+	if got, want := out.String(), "text \"a \ufffc b \ufffc no image\"\n"; got != want {
+		t.Fatalf("unexpected output:\n%s\nexpected:\n%s", got, want)
+	}
+
+	var text *block.TextBlock
+	v.State().ForEach(func(b block.WidgetBlock) bool {
+		text, _ = b.(*block.TextBlock)
+		return true
+	})
+
+	tests := []struct {
+		offset  int
+		tooltip string
+	}{
+		{2, "cat"},
+		{6, ""},
+	}
+
+	for _, test := range tests {
+		anchor := text.Buffer.IterAtOffset(test.offset).ChildAnchor()
+		if anchor == nil {
+			t.Fatalf("no image at offset %d", test.offset)
+		}
+
+		widgets := anchor.Widgets()
+		if len(widgets) != 1 {
+			t.Fatalf("image at offset %d has %d widgets, want 1", test.offset, len(widgets))
+		}
+
+		// The alt text is shown as the tooltip.
+		if tooltip := gtk.BaseWidget(widgets[0]).TooltipText(); tooltip != test.tooltip {
+			t.Errorf("image at offset %d has tooltip %q, want %q", test.offset, tooltip, test.tooltip)
+		}
+	}
+}
+
+// dumpState writes the block tree of the given state, one block per line.
+func dumpState(out *strings.Builder, state *block.ContainerState, depth int) {
+	indent := strings.Repeat("\t", depth)
+
+	state.ForEach(func(b block.WidgetBlock) bool {
+		switch b := b.(type) {
+		case *block.TextBlock:
+			fmt.Fprintf(out, "%stext %q\n", indent, textOf(b))
+		case *block.CodeBlock:
+			fmt.Fprintf(out, "%scode %q\n", indent, textOf(b.TextBlock()))
+		case *block.SeparatorBlock:
+			fmt.Fprintf(out, "%sseparator\n", indent)
+		case *block.Blockquote:
+			fmt.Fprintf(out, "%squote\n", indent)
+			dumpState(out, b.State, depth+1)
+		case *block.ListItemBlock:
+			fmt.Fprintf(out, "%sitem %s", indent, b.ListIndex.Bullet())
+			if b.IsTask() {
+				out.WriteString(" task")
+			}
+			out.WriteByte('\n')
+			dumpState(out, b.State(), depth+1)
+		case *block.TableBlock:
+			fmt.Fprintf(out, "%stable\n", indent)
+			for _, cell := range b.Cells() {
+				fmt.Fprintf(out, "%s\tcell\n", indent)
+				dumpState(out, cell, depth+2)
+			}
+		default:
+			fmt.Fprintf(out, "%s%T\n", indent, b)
+		}
+		return false
+	})
+}
+
+func textOf(b *block.TextBlock) string {
+	start, end := b.Buffer.Bounds()
+	return b.Buffer.Slice(start, end, true)
+}
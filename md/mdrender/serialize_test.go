@@ -0,0 +1,82 @@
+package mdrender
+
+import (
+	"context"
+	"testing"
+
+	"github.com/diamondburned/chatkit/md"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+func TestSerializeRoundTrip(t *testing.T) {
+	if !gtk.InitCheck() {
+		t.Skip("cannot initialize GTK")
+	}
+
+	const mention = "[@alice](https://matrix.to/#/@alice:example.com)"
+
+	// Build a composer buffer the way author.Chip does: the Markdown of the
+	// mention is invisible, followed by a placeholder and the chip's anchor.
+	buf := gtk.NewTextBuffer(nil)
+	buf.SetText("**bold** and ||secret|| to ")
+	iter := buf.EndIter()
+	md.InsertInvisible(iter, mention)
+	buf.Insert(iter, "\u200b")
+	chip := buf.CreateChildAnchor(iter)
+	buf.Insert(iter, " end")
+
+	opts := func(format md.SerializeFormat) md.SerializeOpts {
+		return md.SerializeOpts{
+			Format: format,
+			Anchor: func(anchor *gtk.TextChildAnchor, format md.SerializeFormat) string {
+				if anchor.Native() != chip.Native() {
+					t.Errorf("unexpected anchor")
+				}
+				// The Markdown is already in the invisible text.
+				if format == md.SerializePlainText {
+					return "@alice"
+				}
+				return ""
+			},
+		}
+	}
+
+	const (
+		wantMarkdown = "**bold** and ||secret|| to " + mention + " end"
+		wantPlain    = "bold and secret to @alice end"
+	)
+
+	NewWYSIWYG(context.Background(), buf, WYSIWYGOpts{}).Render()
+
+	if tag := buf.TagTable().Lookup(wysiwygPrefix + "b"); tag == nil || !buf.IterAtOffset(2).HasTag(tag) {
+		t.Fatal("WYSIWYG did not render the buffer")
+	}
+
+	if got := md.Serialize(buf, opts(md.SerializeMarkdown)); got != wantMarkdown {
+		t.Errorf("unexpected Markdown:\n got %q\nwant %q", got, wantMarkdown)
+	}
+	if got := md.Serialize(buf, opts(md.SerializePlainText)); got != wantPlain {
+		t.Errorf("unexpected plain text:\n got %q\nwant %q", got, wantPlain)
+	}
+
+	// The Markdown renders back into the same Markdown.
+	again := gtk.NewTextBuffer(nil)
+	again.SetText(wantMarkdown)
+	NewWYSIWYG(context.Background(), again, WYSIWYGOpts{}).Render()
+
+	if got := md.Serialize(again, md.SerializeOpts{}); got != wantMarkdown {
+		t.Errorf("Markdown did not round-trip:\n got %q\nwant %q", got, wantMarkdown)
+	}
+
+	// Ranges that start within invisible text only keep its visible part in
+	// plain text.
+	start := buf.IterAtOffset(len([]rune("**bold** and ||secret|| to [@")))
+	_, end := buf.Bounds()
+
+	if got := md.SerializeRange(start, end, opts(md.SerializeMarkdown)); got != mention[2:]+" end" {
+		t.Errorf("unexpected Markdown of range: %q", got)
+	}
+	if got := md.SerializeRange(start, end, opts(md.SerializePlainText)); got != "@alice end" {
+		t.Errorf("unexpected plain text of range: %q", got)
+	}
+}
@@ -19,6 +19,10 @@ func NewMarkdownViewer(ctx context.Context, src []byte, n ast.Node, opts ...Opti
 	r := NewRenderer(src, v.State(), opts...)
 	r.Render(ctx, n)
 
+	if r.jumboEmojis > 0 {
+		v.EnlargeEmojis(r.jumboEmojis)
+	}
+
 	return &MarkdownViewer{
 		Viewer: v,
 	}
@@ -12,6 +12,8 @@ import (
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/diamondburned/gotkit/gtkutil/textutil"
 	"github.com/yuin/goldmark/ast"
+
+	extast "github.com/yuin/goldmark/extension/ast"
 )
 
 const wysiwygPrefix = "_wysiwyg_"
@@ -40,6 +42,11 @@ type WYSIWYGOpts struct {
 	Walker func(*WYSIWYG, ast.Node) ast.WalkStatus
 	// SkipHTML, if true, will not highlight HTML tags.
 	SkipHTML bool
+	// ReplaceEmojis, if true, replaces complete emoji shortcodes in the buffer
+	// with their emojis, so the emoji is shown as soon as the shortcode is
+	// typed. Otherwise, shortcodes are only highlighted, and BindEmojiTooltip
+	// can be used to preview them.
+	ReplaceEmojis bool
 }
 
 // wysiwyg is the What-You-See-Is-What-You-Get node walker/highlighter.
@@ -75,6 +82,10 @@ func RenderWYSIWYG(ctx context.Context, buffer *gtk.TextBuffer) {
 // Render renders the WYSIWYG content using the current content inside the
 // buffer. The Head and Tail iterators are revalidated.
 func (w *WYSIWYG) Render() {
+	if w.opts.ReplaceEmojis {
+		w.replaceEmojis()
+	}
+
 	w.Head, w.Tail = w.Buffer.Bounds()
 	w.Source = []byte(w.Buffer.Slice(w.Head, w.Tail, true))
 
@@ -95,6 +106,109 @@ func (w *WYSIWYG) Render() {
 	md.ParseAndWalk(w.Source, w.walker)
 }
 
+// BindEmojiTooltip shows the emoji of the shortcode under the pointer or the
+// cursor as a tooltip of the given TextView. The buffer of the TextView must be
+// rendered using WYSIWYG.
+func BindEmojiTooltip(tview *gtk.TextView) {
+	buf := tview.Buffer()
+
+	tview.SetHasTooltip(true)
+	tview.ConnectQueryTooltip(func(x, y int, keyboard bool, tooltip *gtk.Tooltip) bool {
+		var it *gtk.TextIter
+		if keyboard {
+			it = buf.IterAtMark(buf.GetInsert())
+		} else {
+			bx, by := tview.WindowToBufferCoords(gtk.TextWindowWidget, x, y)
+			var ok bool
+			if it, ok = tview.IterAtLocation(bx, by); !ok {
+				return false
+			}
+		}
+
+		emoji, ok := emojiAtIter(buf, it)
+		if !ok {
+			return false
+		}
+
+		tooltip.SetText(emoji)
+		return true
+	})
+}
+
+// emojiAtIter returns the emoji of the shortcode at the given iterator.
+func emojiAtIter(buf *gtk.TextBuffer, it *gtk.TextIter) (string, bool) {
+	tag := buf.TagTable().Lookup(wysiwygPrefix + "emojishortcode")
+	if tag == nil || !it.HasTag(tag) {
+		return "", false
+	}
+
+	start := it.Copy()
+	if !start.StartsTag(tag) {
+		start.BackwardToTagToggle(tag)
+	}
+	end := it.Copy()
+	end.ForwardToTagToggle(tag)
+
+	// Adjacent shortcodes share the same tagged range, so find the one that
+	// contains the iterator. Shortcodes can't contain colons.
+	text := []rune(buf.Slice(start, end, true))
+	offset := it.Offset() - start.Offset()
+
+	for i := 0; i < len(text); {
+		if text[i] != ':' {
+			break
+		}
+
+		j := i + 1
+		for j < len(text) && text[j] != ':' {
+			j++
+		}
+		if j == len(text) {
+			break
+		}
+
+		if offset <= j {
+			return md.LookupEmoji(string(text[i+1 : j]))
+		}
+		i = j + 1
+	}
+
+	return "", false
+}
+
+// replaceEmojis replaces the complete emoji shortcodes in the buffer with
+// their emojis. Shortcodes in text that isn't editable, such as invisible
+// text, are left alone.
+func (w *WYSIWYG) replaceEmojis() {
+	start, end := w.Buffer.Bounds()
+	src := []byte(w.Buffer.Slice(start, end, true))
+
+	var emojis []*md.Emoji
+	md.ParseAndWalk(src, func(n ast.Node, enter bool) (ast.WalkStatus, error) {
+		if emoji, ok := n.(*md.Emoji); ok && enter {
+			emojis = append(emojis, emoji)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	// Replace from the end, so the positions of the earlier shortcodes stay
+	// the same.
+	for i := len(emojis) - 1; i >= 0; i-- {
+		emoji := emojis[i]
+
+		// Editing the buffer invalidates all iterators.
+		start, end := w.Buffer.Bounds()
+		SetIter(start, src, emoji.Segment.Start)
+		SetIter(end, src, emoji.Segment.Stop)
+		if !start.Editable(true) || !end.Editable(true) {
+			continue
+		}
+
+		w.Buffer.Delete(start, end)
+		w.Buffer.Insert(start, emoji.Unicode)
+	}
+}
+
 func (w *WYSIWYG) walker(n ast.Node, enter bool) (ast.WalkStatus, error) {
 	if !enter {
 		return ast.WalkContinue, nil
@@ -119,6 +233,17 @@ func (w *WYSIWYG) enter(n ast.Node) ast.WalkStatus {
 		w.MarkText(n, tag)
 		return ast.WalkSkipChildren
 
+	case *extast.Strikethrough:
+		w.MarkText(n, "del")
+		return ast.WalkSkipChildren
+
+	case *md.Spoiler:
+		w.MarkText(n, "spoiler")
+		return ast.WalkContinue
+
+	case *md.Emoji:
+		w.MarkBounds(n.Segment.Start, n.Segment.Stop, "emojishortcode")
+
 	case *ast.Heading:
 		// h1 ~ h6
 		if n.Level >= 1 && n.Level <= 6 {
@@ -137,6 +262,22 @@ func (w *WYSIWYG) enter(n ast.Node) ast.WalkStatus {
 		w.MarkTextTags(n, linkTags.FromTable(w.Tags, "a"))
 		return ast.WalkSkipChildren
 
+	case *ast.AutoLink:
+		start, stop, ok := autoLinkBounds(n, w.Source)
+		if !ok {
+			return ast.WalkSkipChildren
+		}
+
+		w.SetIter(w.Head, start)
+		w.SetIter(w.Tail, stop)
+
+		if !w.BoundIsInvisible() {
+			linkTags := textutil.LinkTags()
+			w.Buffer.ApplyTag(linkTags.FromTable(w.Tags, "a"), w.Head, w.Tail)
+		}
+
+		return ast.WalkSkipChildren
+
 	case *ast.CodeSpan:
 		w.MarkText(n, "code")
 		return ast.WalkSkipChildren
@@ -147,6 +288,12 @@ func (w *WYSIWYG) enter(n ast.Node) ast.WalkStatus {
 			w.MarkBounds(seg.Start, seg.Stop, "htmltag")
 		}
 
+	case *ast.ListItem:
+		w.markListMarker(n)
+
+	case *extast.TaskCheckBox:
+		w.markTaskCheckBox(n)
+
 	case *ast.FencedCodeBlock:
 		lines := n.Lines()
 
@@ -173,6 +320,127 @@ func (w *WYSIWYG) enter(n ast.Node) ast.WalkStatus {
 	return ast.WalkContinue
 }
 
+// markListMarker marks the bullet or number of the given list item.
+func (w *WYSIWYG) markListMarker(n *ast.ListItem) {
+	first := n.FirstChild()
+	if first == nil || first.Lines().Len() == 0 {
+		return
+	}
+
+	// The marker is right before the item's content, separated by spaces.
+	end := first.Lines().At(0).Start
+	for end > 0 && isSpace(w.Source[end-1]) {
+		end--
+	}
+
+	start := end
+	for start > 0 && isListMarker(w.Source[start-1]) {
+		start--
+	}
+
+	if start < end {
+		w.MarkBounds(start, end, "listmarker")
+	}
+}
+
+// markTaskCheckBox marks the "[ ]" or "[x]" of a task list item.
+func (w *WYSIWYG) markTaskCheckBox(n *extast.TaskCheckBox) {
+	lines := n.Parent().Lines()
+	if lines.Len() == 0 {
+		return
+	}
+
+	// The checkbox is always at the start of the list item's first line.
+	start := lines.At(0).Start
+	if start+3 <= len(w.Source) && w.Source[start] == '[' {
+		w.MarkBounds(start, start+3, "listmarker")
+	}
+}
+
+// autoLinkBounds returns the byte bounds of the label of the given AutoLink.
+// AutoLink nodes don't keep the position of their labels, so the label is
+// searched for right after whatever precedes the node.
+func autoLinkBounds(n *ast.AutoLink, src []byte) (int, int, bool) {
+	from, ok := precedingStop(n, src)
+	if !ok {
+		return 0, 0, false
+	}
+
+	label := n.Label(src)
+
+	i := bytes.Index(src[from:], label)
+	if i < 0 {
+		return 0, 0, false
+	}
+
+	return from + i, from + i + len(label), true
+}
+
+// precedingStop returns the byte offset right after the last node with a
+// known position before n, or the start of the block that n is in.
+func precedingStop(n ast.Node, src []byte) (int, bool) {
+	for ; n != nil; n = n.Parent() {
+		if n.Type() == ast.TypeBlock {
+			if n.Lines().Len() == 0 {
+				return 0, false
+			}
+			return n.Lines().At(0).Start, true
+		}
+
+		for prev := n.PreviousSibling(); prev != nil; prev = prev.PreviousSibling() {
+			if stop, ok := lastStop(prev, src); ok {
+				return stop, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// lastStop returns the byte offset right after the last positioned node
+// within n.
+func lastStop(n ast.Node, src []byte) (int, bool) {
+	switch n := n.(type) {
+	case *ast.Text:
+		return n.Segment.Stop, true
+	case *ast.RawHTML:
+		if n.Segments.Len() > 0 {
+			return n.Segments.At(n.Segments.Len() - 1).Stop, true
+		}
+	case *md.Emoji:
+		return n.Segment.Stop, true
+	case *ast.AutoLink:
+		_, stop, ok := autoLinkBounds(n, src)
+		return stop, ok
+	}
+
+	for c := n.LastChild(); c != nil; c = c.PreviousSibling() {
+		if stop, ok := lastStop(c, src); ok {
+			return stop, true
+		}
+	}
+
+	return 0, false
+}
+
+func isSpace(b byte) bool {
+	switch b {
+	case ' ', '\t', '\n', '\r':
+		return true
+	default:
+		return false
+	}
+}
+
+func isListMarker(b byte) bool {
+	switch b {
+	case '-', '+', '*', '.', ')':
+		return true
+	default:
+		return '0' <= b && b <= '9'
+	}
+}
+
 func (w *WYSIWYG) tag(tagName string) *gtk.TextTag {
 	return wysiwygTag(w.Tags, tagName)
 }
@@ -0,0 +1,81 @@
+package mdrender
+
+import (
+	"context"
+	"testing"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+func TestEmojiAtIter(t *testing.T) {
+	if !gtk.InitCheck() {
+		t.Skip("cannot initialize GTK")
+	}
+
+	buf := gtk.NewTextBuffer(nil)
+	buf.SetText("hi :eyes: :nope: :+1::fire:")
+	NewWYSIWYG(context.Background(), buf, WYSIWYGOpts{}).Render()
+
+	tests := []struct {
+		offset int
+		emoji  string
+		ok     bool
+	}{
+		{0, "", false},
+		{3, "👀", true},
+		{6, "👀", true},
+		{8, "👀", true},
+		{9, "", false},
+		{12, "", false},
+		// Adjacent shortcodes are one tagged range.
+		{17, "👍", true},
+		{20, "👍", true},
+		{21, "🔥", true},
+		{26, "🔥", true},
+		{27, "", false},
+	}
+
+	for _, test := range tests {
+		emoji, ok := emojiAtIter(buf, buf.IterAtOffset(test.offset))
+		if emoji != test.emoji || ok != test.ok {
+			t.Errorf("emojiAtIter(%d) = (%q, %v), want (%q, %v)",
+				test.offset, emoji, ok, test.emoji, test.ok)
+		}
+	}
+}
+
+func TestWYSIWYGReplaceEmojis(t *testing.T) {
+	if !gtk.InitCheck() {
+		t.Skip("cannot initialize GTK")
+	}
+
+	buf := gtk.NewTextBuffer(nil)
+	buf.SetText("hi :eyes::fire: :nope: `:tada:`")
+	NewWYSIWYG(context.Background(), buf, WYSIWYGOpts{ReplaceEmojis: true}).Render()
+
+	start, end := buf.Bounds()
+	if text := buf.Slice(start, end, true); text != "hi 👀🔥 :nope: `:tada:`" {
+		t.Errorf("unexpected text after replacing emojis: %q", text)
+	}
+}
+
+func TestWYSIWYGStrikethrough(t *testing.T) {
+	if !gtk.InitCheck() {
+		t.Skip("cannot initialize GTK")
+	}
+
+	buf := gtk.NewTextBuffer(nil)
+	buf.SetText("a ~~b~~ `~~c~~`")
+	NewWYSIWYG(context.Background(), buf, WYSIWYGOpts{}).Render()
+
+	del := buf.TagTable().Lookup(wysiwygPrefix + "del")
+	if del == nil {
+		t.Fatal("strikethrough is not highlighted")
+	}
+
+	for offset, want := range map[int]bool{0: false, 4: true, 11: false} {
+		if buf.IterAtOffset(offset).HasTag(del) != want {
+			t.Errorf("offset %d: expected struck through = %v", offset, want)
+		}
+	}
+}
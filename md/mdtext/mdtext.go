@@ -0,0 +1,232 @@
+// Package mdtext renders Markdown ASTs into plain text. It is meant for
+// places where formatting cannot be shown, such as notifications, reply
+// previews, window titles and accessibility labels.
+package mdtext
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/diamondburned/chatkit/md"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+
+	extast "github.com/yuin/goldmark/extension/ast"
+)
+
+// Opts contains options for rendering plain text.
+type Opts struct {
+	// MaxRunes is the maximum number of runes in the output. If the text is
+	// longer, then it is truncated and ellipsized. 0 means no limit.
+	MaxRunes int
+	// MaxLines is the maximum number of lines in the output. If the text has
+	// more lines, then it is truncated and ellipsized. 0 means no limit.
+	MaxLines int
+	// CodeBlock is the placeholder for code blocks. If empty, then
+	// DefaultCodeBlock is used.
+	CodeBlock string
+	// Spoiler is the placeholder for spoilers. If empty, then DefaultSpoiler
+	// is used.
+	Spoiler string
+}
+
+// Default placeholders.
+const (
+	DefaultCodeBlock = "[code]"
+	DefaultSpoiler   = "[spoiler]"
+	DefaultImage     = "[image]"
+)
+
+// Ellipsis is appended to truncated text.
+const Ellipsis = "…"
+
+// RenderString parses src using md.Parser and renders it.
+func RenderString(src string, opts Opts) string {
+	b := []byte(src)
+	return Render(b, md.Parser.Parse(text.NewReader(b)), opts)
+}
+
+// Render renders the AST n of the given source into plain text.
+func Render(src []byte, n ast.Node, opts Opts) string {
+	if opts.CodeBlock == "" {
+		opts.CodeBlock = DefaultCodeBlock
+	}
+	if opts.Spoiler == "" {
+		opts.Spoiler = DefaultSpoiler
+	}
+
+	r := renderer{src: src, opts: opts}
+	r.render(n)
+
+	return truncate(strings.TrimSpace(r.out.String()), opts)
+}
+
+type renderer struct {
+	out  strings.Builder
+	src  []byte
+	opts Opts
+}
+
+// sub renders the children of n into a new string.
+func (r *renderer) sub(n ast.Node) string {
+	sub := renderer{src: r.src, opts: r.opts}
+	sub.renderChildren(n)
+	return strings.TrimRight(sub.out.String(), "\n")
+}
+
+// endLine ensures that the output ends with a new line.
+func (r *renderer) endLine() {
+	s := r.out.String()
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		r.out.WriteByte('\n')
+	}
+}
+
+func (r *renderer) renderChildren(n ast.Node) {
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		r.render(c)
+	}
+}
+
+func (r *renderer) render(n ast.Node) {
+	switch n := n.(type) {
+	case *ast.Text:
+		r.out.Write(n.Segment.Value(r.src))
+		if n.HardLineBreak() || n.SoftLineBreak() {
+			r.out.WriteByte('\n')
+		}
+
+	case *ast.String:
+		r.out.Write(n.Value)
+
+	case *ast.CodeSpan:
+		r.renderChildren(n)
+
+	case *ast.Link:
+		label := r.sub(n)
+		url := string(n.Destination)
+		r.out.WriteString(label)
+		if label != url {
+			r.out.WriteString(" (" + url + ")")
+		}
+
+	case *ast.AutoLink:
+		r.out.Write(n.Label(r.src))
+
+	case *ast.Image:
+		if alt := string(n.Text(r.src)); alt != "" {
+			r.out.WriteString(alt)
+		} else {
+			r.out.WriteString(DefaultImage)
+		}
+
+	case *md.Spoiler:
+		r.out.WriteString(r.opts.Spoiler)
+
+	case *md.Emoji:
+		r.out.WriteString(n.Unicode)
+
+	case *ast.RawHTML:
+		// Drop the tags but keep whatever is between them.
+
+	case *ast.HTMLBlock:
+		// Raw HTML is not readable as plain text.
+
+	case *ast.FencedCodeBlock, *ast.CodeBlock:
+		r.endLine()
+		r.out.WriteString(r.opts.CodeBlock)
+		r.out.WriteByte('\n')
+
+	case *ast.ThematicBreak:
+		r.endLine()
+
+	case *ast.Paragraph, *ast.TextBlock, *ast.Heading:
+		r.endLine()
+		r.renderChildren(n)
+		r.endLine()
+
+	case *ast.Blockquote:
+		r.endLine()
+		r.out.WriteString(prefixLines(r.sub(n), "> ", "> "))
+		r.out.WriteByte('\n')
+
+	case *ast.List:
+		r.endLine()
+		index := n.Start
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			bullet := "• "
+			if n.IsOrdered() {
+				bullet = strconv.Itoa(index) + ". "
+				index++
+			}
+
+			indent := strings.Repeat(" ", utf8.RuneCountInString(bullet))
+			r.out.WriteString(prefixLines(r.sub(c), bullet, indent))
+			r.out.WriteByte('\n')
+		}
+
+	case *extast.TaskCheckBox:
+		if n.IsChecked {
+			r.out.WriteString("[x] ")
+		} else {
+			r.out.WriteString("[ ] ")
+		}
+
+	case *extast.Table:
+		r.endLine()
+		r.renderChildren(n)
+
+	case *extast.TableHeader, *extast.TableRow:
+		cells := make([]string, 0, n.ChildCount())
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			cells = append(cells, r.sub(c))
+		}
+		r.out.WriteString(strings.Join(cells, " | "))
+		r.out.WriteByte('\n')
+
+	default:
+		r.renderChildren(n)
+	}
+}
+
+// prefixLines prefixes the first line of s with first and all other lines
+// with rest.
+func prefixLines(s, first, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if i == 0 {
+			lines[i] = first + line
+		} else {
+			lines[i] = rest + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// truncate truncates s according to the limits in opts.
+func truncate(s string, opts Opts) string {
+	var truncated bool
+
+	if opts.MaxLines > 0 {
+		lines := strings.SplitN(s, "\n", opts.MaxLines+1)
+		if len(lines) > opts.MaxLines {
+			s = strings.Join(lines[:opts.MaxLines], "\n")
+			truncated = true
+		}
+	}
+
+	if opts.MaxRunes > 0 && utf8.RuneCountInString(s) > opts.MaxRunes {
+		// Leave space for the ellipsis.
+		runes := []rune(s)
+		s = string(runes[:opts.MaxRunes-1])
+		truncated = true
+	}
+
+	if truncated {
+		s = strings.TrimRightFunc(s, func(r rune) bool { return r == ' ' || r == '\n' })
+		s += Ellipsis
+	}
+
+	return s
+}
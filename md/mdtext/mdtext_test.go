@@ -0,0 +1,64 @@
+package mdtext
+
+import "testing"
+
+func TestRenderString(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		opts Opts
+		out  string
+	}{
+		{
+			name: "emphasis",
+			in:   "hello, **world** _and_ `code`",
+			out:  "hello, world and code",
+		},
+		{
+			name: "link",
+			in:   "see [the docs](https://example.com) or <https://example.org>",
+			out:  "see the docs (https://example.com) or https://example.org",
+		},
+		{
+			name: "code block",
+			in:   "look:\n\n```go\nfunc main() {}\n```\n\nnice",
+			out:  "look:\n[code]\nnice",
+		},
+		{
+			name: "list",
+			in:   "- a\n- b\n  1. c\n  2. d\n- [x] e",
+			out:  "• a\n• b\n  1. c\n  2. d\n• [x] e",
+		},
+		{
+			name: "quote",
+			in:   "> a\n> b\n\nc",
+			out:  "> a\n> b\nc",
+		},
+		{
+			name: "spoiler",
+			in:   "the ending is ||secret||",
+			out:  "the ending is [spoiler]",
+		},
+		{
+			name: "max lines",
+			in:   "a\nb\nc",
+			opts: Opts{MaxLines: 2},
+			out:  "a\nb…",
+		},
+		{
+			name: "max runes",
+			in:   "hello world",
+			opts: Opts{MaxRunes: 6},
+			out:  "hello…",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := RenderString(test.in, test.opts)
+			if out != test.out {
+				t.Errorf("unexpected output:\n got %q\nwant %q", out, test.out)
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package md
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+	"golang.org/x/net/html"
+)
+
+// HTMLPolicy describes the HTML that a sanitizing renderer is allowed to
+// output. Anything else is escaped and shown as text.
+type HTMLPolicy struct {
+	// Tags maps each allowed tag to the list of its allowed attributes.
+	Tags map[string][]string
+	// LinkSchemes is the list of URL schemes allowed in links.
+	LinkSchemes []string
+	// ImageSchemes is the list of URL schemes allowed in image sources.
+	ImageSchemes []string
+	// ClassPrefixes, if not empty, only allows classes with one of these
+	// prefixes in class attributes.
+	ClassPrefixes []string
+}
+
+// MatrixHTMLPolicy is the policy that follows the HTML subset suggested by the
+// Matrix specification.
+var MatrixHTMLPolicy = HTMLPolicy{
+	Tags: map[string][]string{
+		"font":       {"data-mx-bg-color", "data-mx-color", "color"},
+		"del":        nil,
+		"h1":         nil,
+		"h2":         nil,
+		"h3":         nil,
+		"h4":         nil,
+		"h5":         nil,
+		"h6":         nil,
+		"blockquote": nil,
+		"p":          nil,
+		"a":          {"name", "target", "href"},
+		"ul":         nil,
+		"ol":         {"start"},
+		"sup":        nil,
+		"sub":        nil,
+		"li":         nil,
+		"b":          nil,
+		"i":          nil,
+		"u":          nil,
+		"strong":     nil,
+		"em":         nil,
+		"strike":     nil,
+		"code":       {"class"},
+		"hr":         nil,
+		"br":         nil,
+		"div":        nil,
+		"table":      nil,
+		"thead":      nil,
+		"tbody":      nil,
+		"tr":         nil,
+		"th":         nil,
+		"td":         nil,
+		"caption":    nil,
+		"pre":        nil,
+		"span":       {"data-mx-bg-color", "data-mx-color", "data-mx-spoiler"},
+		"img":        {"width", "height", "alt", "title", "src"},
+		"details":    nil,
+		"summary":    nil,
+	},
+	LinkSchemes:   LinkSchemes,
+	ImageSchemes:  []string{"mxc"},
+	ClassPrefixes: []string{"language-"},
+}
+
+// allowsTag returns true if the policy allows the given tag.
+func (p *HTMLPolicy) allowsTag(tag string) bool {
+	_, ok := p.Tags[tag]
+	return ok
+}
+
+// allowsAttr returns true if the policy allows the given attribute.
+func (p *HTMLPolicy) allowsAttr(tag, attr string) bool {
+	for _, allowed := range p.Tags[tag] {
+		if allowed == attr {
+			return true
+		}
+	}
+	return false
+}
+
+// LinkSchemes is the list of URL schemes that are rendered and sent as
+// clickable links. Links with other schemes are shown as plain text.
+var LinkSchemes = []string{"https", "http", "ftp", "mailto", "magnet", "matrix"}
+
+// IsLinkURL returns true if rawURL is a URL with one of the LinkSchemes.
+func IsLinkURL(rawURL string) bool {
+	return allowsURL(LinkSchemes, rawURL)
+}
+
+// allowsURL returns true if the URL has one of the given schemes.
+func allowsURL(schemes []string, rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	for _, scheme := range schemes {
+		if strings.EqualFold(u.Scheme, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterClasses returns the classes in value that are allowed by the policy.
+func (p *HTMLPolicy) filterClasses(value string) string {
+	if len(p.ClassPrefixes) == 0 {
+		return value
+	}
+
+	classes := strings.Fields(value)
+	filtered := classes[:0]
+
+	for _, class := range classes {
+		for _, prefix := range p.ClassPrefixes {
+			if strings.HasPrefix(class, prefix) {
+				filtered = append(filtered, class)
+				break
+			}
+		}
+	}
+
+	return strings.Join(filtered, " ")
+}
+
+// Sanitize sanitizes the given HTML. Allowed tags are written out with only
+// their allowed attributes, while everything else is escaped.
+func (p *HTMLPolicy) Sanitize(src []byte) []byte {
+	var buf bytes.Buffer
+	p.sanitize(&buf, src)
+	return buf.Bytes()
+}
+
+func (p *HTMLPolicy) sanitize(w *bytes.Buffer, src []byte) {
+	tokenizer := html.NewTokenizer(bytes.NewReader(src))
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			// The tokenizer only fails on EOF, since we're reading from
+			// memory.
+			return
+		case html.TextToken:
+			w.WriteString(html.EscapeString(string(tokenizer.Text())))
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			// Copy the raw tag, since Token may change it.
+			raw := string(tokenizer.Raw())
+			token := tokenizer.Token()
+			if !p.allowsTag(token.Data) {
+				w.WriteString(html.EscapeString(raw))
+				continue
+			}
+			p.writeTag(w, token)
+		default:
+			// Comments and doctypes.
+			w.WriteString(html.EscapeString(string(tokenizer.Raw())))
+		}
+	}
+}
+
+func (p *HTMLPolicy) writeTag(w *bytes.Buffer, token html.Token) {
+	if token.Type == html.EndTagToken {
+		w.WriteString("</")
+		w.WriteString(token.Data)
+		w.WriteByte('>')
+		return
+	}
+
+	w.WriteByte('<')
+	w.WriteString(token.Data)
+
+	for _, attr := range token.Attr {
+		if attr.Namespace != "" || !p.allowsAttr(token.Data, attr.Key) {
+			continue
+		}
+
+		switch {
+		case token.Data == "a" && attr.Key == "href":
+			if !allowsURL(p.LinkSchemes, attr.Val) {
+				continue
+			}
+		case token.Data == "img" && attr.Key == "src":
+			if !allowsURL(p.ImageSchemes, attr.Val) {
+				continue
+			}
+		case attr.Key == "class":
+			attr.Val = p.filterClasses(attr.Val)
+			if attr.Val == "" {
+				continue
+			}
+		}
+
+		w.WriteByte(' ')
+		w.WriteString(attr.Key)
+		w.WriteString(`="`)
+		w.WriteString(html.EscapeString(attr.Val))
+		w.WriteByte('"')
+	}
+
+	if token.Type == html.SelfClosingTagToken {
+		w.WriteString(" />")
+	} else {
+		w.WriteByte('>')
+	}
+}
+
+type sanitizingHTMLRenderer struct {
+	policy *HTMLPolicy
+}
+
+// NewSanitizingHTMLRenderer returns a new NodeRenderer that renders raw HTML,
+// links and images according to the given policy. Disallowed HTML is escaped
+// instead of being omitted or passed through. It must have a higher priority
+// than the default HTML renderer.
+func NewSanitizingHTMLRenderer(policy *HTMLPolicy) renderer.NodeRenderer {
+	return sanitizingHTMLRenderer{policy}
+}
+
+// RegisterFuncs implements renderer.NodeRenderer.
+func (r sanitizingHTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindRawHTML, r.renderRawHTML)
+	reg.Register(ast.KindHTMLBlock, r.renderHTMLBlock)
+	reg.Register(ast.KindLink, r.renderLink)
+	reg.Register(ast.KindAutoLink, r.renderAutoLink)
+	reg.Register(ast.KindImage, r.renderImage)
+}
+
+func (r sanitizingHTMLRenderer) renderRawHTML(w util.BufWriter, src []byte, node ast.Node, enter bool) (ast.WalkStatus, error) {
+	if enter {
+		n := node.(*ast.RawHTML)
+
+		var raw bytes.Buffer
+		for i := 0; i < n.Segments.Len(); i++ {
+			segment := n.Segments.At(i)
+			raw.Write(segment.Value(src))
+		}
+
+		w.Write(r.policy.Sanitize(raw.Bytes()))
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func (r sanitizingHTMLRenderer) renderHTMLBlock(w util.BufWriter, src []byte, node ast.Node, enter bool) (ast.WalkStatus, error) {
+	n := node.(*ast.HTMLBlock)
+
+	var raw bytes.Buffer
+	if enter {
+		for i := 0; i < n.Lines().Len(); i++ {
+			line := n.Lines().At(i)
+			raw.Write(line.Value(src))
+		}
+	} else if n.HasClosure() {
+		raw.Write(n.ClosureLine.Value(src))
+	}
+
+	w.Write(r.policy.Sanitize(raw.Bytes()))
+	return ast.WalkContinue, nil
+}
+
+func (r sanitizingHTMLRenderer) renderLink(w util.BufWriter, src []byte, node ast.Node, enter bool) (ast.WalkStatus, error) {
+	n := node.(*ast.Link)
+	if !r.policy.allowsTag("a") || !allowsURL(r.policy.LinkSchemes, string(n.Destination)) {
+		// Only render the link text.
+		return ast.WalkContinue, nil
+	}
+
+	if enter {
+		w.WriteString(`<a href="`)
+		w.Write(util.EscapeHTML(util.URLEscape(n.Destination, true)))
+		w.WriteString(`">`)
+	} else {
+		w.WriteString("</a>")
+	}
+
+	return ast.WalkContinue, nil
+}
+
+func (r sanitizingHTMLRenderer) renderAutoLink(w util.BufWriter, src []byte, node ast.Node, enter bool) (ast.WalkStatus, error) {
+	if !enter {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*ast.AutoLink)
+	url := []byte(AutoLinkURL(n, src))
+	label := util.EscapeHTML(n.Label(src))
+
+	if !r.policy.allowsTag("a") || !allowsURL(r.policy.LinkSchemes, string(url)) {
+		w.Write(label)
+		return ast.WalkContinue, nil
+	}
+
+	w.WriteString(`<a href="`)
+	w.Write(util.EscapeHTML(util.URLEscape(url, false)))
+	w.WriteString(`">`)
+	w.Write(label)
+	w.WriteString("</a>")
+
+	return ast.WalkContinue, nil
+}
+
+func (r sanitizingHTMLRenderer) renderImage(w util.BufWriter, src []byte, node ast.Node, enter bool) (ast.WalkStatus, error) {
+	if !enter {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*ast.Image)
+	alt := util.EscapeHTML(n.Text(src))
+
+	if !r.policy.allowsTag("img") || !allowsURL(r.policy.ImageSchemes, string(n.Destination)) {
+		// Degrade to the alt text.
+		w.Write(alt)
+		return ast.WalkSkipChildren, nil
+	}
+
+	w.WriteString(`<img src="`)
+	w.Write(util.EscapeHTML(util.URLEscape(n.Destination, true)))
+	w.WriteString(`" alt="`)
+	w.Write(alt)
+	w.WriteByte('"')
+	if n.Title != nil {
+		w.WriteString(` title="`)
+		w.Write(util.EscapeHTML(n.Title))
+		w.WriteByte('"')
+	}
+	w.WriteString(" />")
+
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,90 @@
+package md
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestConverterSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{
+			name: "allowed inline",
+			in:   "a <b>bold</b> <u onclick=\"x\">text</u>",
+			out:  "<p>a <b>bold</b> <u>text</u></p>\n",
+		},
+		{
+			name: "disallowed inline",
+			in:   "a <marquee>b</marquee>",
+			out:  "<p>a &lt;marquee&gt;b&lt;/marquee&gt;</p>\n",
+		},
+		{
+			name: "script block",
+			in:   "<script>alert(1)</script>",
+			out:  "&lt;script&gt;alert(1)&lt;/script&gt;",
+		},
+		{
+			name: "link",
+			in:   "[a](https://example.com) [b](javascript:alert(1))",
+			out:  "<p><a href=\"https://example.com\">a</a> b</p>\n",
+		},
+		{
+			name: "matrix link",
+			in:   "[room](matrix:r/room:example.com)",
+			out:  "<p><a href=\"matrix:r/room:example.com\">room</a></p>\n",
+		},
+		{
+			name: "raw link",
+			in:   "<a href=\"javascript:alert(1)\" style=\"x\">a</a>",
+			out:  "<p><a>a</a></p>\n",
+		},
+		{
+			name: "image",
+			in:   "![cat](https://example.com/cat.png) ![dog](mxc://example.com/dog)",
+			out:  "<p>cat <img src=\"mxc://example.com/dog\" alt=\"dog\" /></p>\n",
+		},
+		{
+			name: "code class",
+			in:   "<code class=\"language-go evil\">x</code>",
+			out:  "<p><code class=\"language-go\">x</code></p>\n",
+		},
+		{
+			name: "comment",
+			in:   "a <!-- b -->",
+			out:  "<p>a &lt;!-- b --&gt;</p>\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := Converter.Convert([]byte(test.in), &out); err != nil {
+				t.Fatal("cannot convert:", err)
+			}
+			if out.String() != test.out {
+				t.Errorf("unexpected output:\n got %q\nwant %q", out.String(), test.out)
+			}
+		})
+	}
+}
+
+func TestIsLinkURL(t *testing.T) {
+	for href, valid := range map[string]bool{
+		"https://example.com":        true,
+		"HTTP://example.com":         true,
+		"mailto:me@example.com":      true,
+		"matrix:r/room:example.com":  true,
+		"javascript:alert(1)":        false,
+		"file:///etc/passwd":         false,
+		"data:text/html,<b>hi</b>":   false,
+		"relative/path":              false,
+		"vbscript:msgbox(\"hello\")": false,
+	} {
+		if IsLinkURL(href) != valid {
+			t.Errorf("IsLinkURL(%q) = %v, want %v", href, !valid, valid)
+		}
+	}
+}
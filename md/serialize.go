@@ -0,0 +1,204 @@
+package md
+
+import (
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// SerializeFormat is the format that a TextBuffer is serialized to.
+type SerializeFormat uint8
+
+const (
+	// SerializeMarkdown serializes the buffer to Markdown. Text inserted using
+	// InsertInvisible is kept, so the output is the same source that
+	// mdrender.WYSIWYG renders.
+	SerializeMarkdown SerializeFormat = iota
+	// SerializePlainText serializes the buffer to the text that the user
+	// sees without its Markdown syntax. Text inserted using InsertInvisible is
+	// dropped, as are emphasis and spoiler markers, heading and quote
+	// prefixes, list bullets and code fences. Links are replaced with their
+	// text and emoji shortcodes with their emojis.
+	SerializePlainText
+)
+
+// SerializeOpts contains options for serializing a TextBuffer.
+type SerializeOpts struct {
+	// Format is the output format.
+	Format SerializeFormat
+	// Anchor is called for each child anchor in the buffer, such as the ones
+	// that author.Chip or inline images are inserted at. The returned string
+	// is written in place of the anchor. If Anchor is nil, then child anchors
+	// are dropped.
+	Anchor func(anchor *gtk.TextChildAnchor, format SerializeFormat) string
+}
+
+// objectReplacementChar is the character that GTK uses for child anchors in
+// the text.
+const objectReplacementChar = '\uFFFC'
+
+// anchorPlaceholder is the character inserted before a child anchor by
+// author.Chip. It is dropped when serializing.
+const anchorPlaceholder = '\u200b'
+
+// Serialize serializes the whole buffer.
+func Serialize(buffer *gtk.TextBuffer, opts SerializeOpts) string {
+	start, end := buffer.Bounds()
+	return SerializeRange(start, end, opts)
+}
+
+// SerializeRange serializes the text between the given iterators.
+func SerializeRange(start, end *gtk.TextIter, opts SerializeOpts) string {
+	buffer := start.Buffer()
+	invisible := Tags.FromTable(buffer.TagTable(), "_invisible")
+
+	var b strings.Builder
+	var w serializeWriter
+	w.b = &b
+
+	head := start.Copy()
+	for head.Compare(end) < 0 {
+		// Split the text into runs of either visible or invisible text.
+		tail := head.Copy()
+		if !tail.ForwardToTagToggle(invisible) || tail.Compare(end) > 0 {
+			tail = end.Copy()
+		}
+
+		if opts.Format != SerializePlainText || !head.HasTag(invisible) {
+			w.writeRun(buffer, head, tail, opts)
+		}
+
+		head = tail
+	}
+
+	if opts.Format != SerializePlainText {
+		return b.String()
+	}
+
+	// Strip the Markdown syntax. The anchors' payloads are substituted
+	// afterwards, so they aren't parsed as Markdown.
+	plain := plainText([]byte(b.String()))
+	for _, payload := range w.payloads {
+		plain = strings.Replace(plain, string(objectReplacementChar), payload, 1)
+	}
+	return plain
+}
+
+type serializeWriter struct {
+	b *strings.Builder
+	// payloads are the payloads of the anchors in order. They are only
+	// collected for SerializePlainText, where each anchor is written as
+	// objectReplacementChar instead.
+	payloads []string
+}
+
+func (w *serializeWriter) writeRun(buffer *gtk.TextBuffer, head, tail *gtk.TextIter, opts SerializeOpts) {
+	b := w.b
+
+	text := buffer.Slice(head, tail, true)
+	if !strings.ContainsRune(text, objectReplacementChar) {
+		b.WriteString(text)
+		return
+	}
+
+	runes := []rune(text)
+	offset := head.Offset()
+
+	for i, r := range runes {
+		switch r {
+		case anchorPlaceholder:
+			// Skip placeholders that come right before an anchor.
+			if i+1 < len(runes) && runes[i+1] == objectReplacementChar {
+				continue
+			}
+		case objectReplacementChar:
+			var payload string
+
+			// The character may also just be typed in by the user.
+			anchor := buffer.IterAtOffset(offset + i).ChildAnchor()
+			if anchor == nil {
+				payload = string(r)
+			} else if opts.Anchor != nil {
+				payload = opts.Anchor(anchor, opts.Format)
+			}
+
+			if opts.Format == SerializePlainText {
+				w.payloads = append(w.payloads, payload)
+				break
+			}
+
+			b.WriteString(payload)
+			continue
+		}
+
+		b.WriteRune(r)
+	}
+}
+
+// plainText parses src as Markdown and returns its text without the syntax.
+func plainText(src []byte) string {
+	r := plainRenderer{src: src}
+	r.renderChildren(Parser.Parse(text.NewReader(src)))
+	return r.out.String()
+}
+
+type plainRenderer struct {
+	out strings.Builder
+	src []byte
+}
+
+func (r *plainRenderer) renderChildren(n ast.Node) {
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		// Keep blocks on their own lines, and keep the blank lines between
+		// them.
+		if c.Type() == ast.TypeBlock && c.PreviousSibling() != nil {
+			if !strings.HasSuffix(r.out.String(), "\n") {
+				r.out.WriteByte('\n')
+			}
+			if c.HasBlankPreviousLines() {
+				r.out.WriteByte('\n')
+			}
+		}
+
+		r.render(c)
+	}
+}
+
+func (r *plainRenderer) render(n ast.Node) {
+	switch n := n.(type) {
+	case *ast.Text:
+		r.out.Write(util.UnescapePunctuations(n.Segment.Value(r.src)))
+		if n.HardLineBreak() || n.SoftLineBreak() {
+			r.out.WriteByte('\n')
+		}
+
+	case *ast.String:
+		r.out.Write(n.Value)
+
+	case *ast.AutoLink:
+		r.out.Write(n.Label(r.src))
+
+	case *Emoji:
+		r.out.WriteString(n.Unicode)
+
+	case *ast.RawHTML, *ast.HTMLBlock:
+		// Raw HTML is not readable as plain text.
+
+	case *ast.FencedCodeBlock, *ast.CodeBlock:
+		lines := n.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			segment := lines.At(i)
+			line := segment.Value(r.src)
+			if i == lines.Len()-1 {
+				line = []byte(strings.TrimSuffix(string(line), "\n"))
+			}
+			r.out.Write(line)
+		}
+
+	default:
+		r.renderChildren(n)
+	}
+}
@@ -0,0 +1,50 @@
+package md
+
+import "testing"
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{
+			name: "inline",
+			in:   "**bold** _it_ ~~gone~~ ||secret|| `co*de*` \\*esc\\*",
+			out:  "bold it gone secret co*de* *esc*",
+		},
+		{
+			name: "links",
+			in:   "[docs](https://docs.example) <https://example.com> ![cat](https://cat.example)",
+			out:  "docs https://example.com cat",
+		},
+		{
+			name: "emoji",
+			in:   "nice :+1:",
+			out:  "nice 👍",
+		},
+		{
+			name: "blocks",
+			in:   "# Title\n\nline one\nline two\n\n> quoted\n\n- a\n- b",
+			out:  "Title\n\nline one\nline two\n\nquoted\n\na\nb",
+		},
+		{
+			name: "code block",
+			in:   "before\n\n```go\nx := **1**\n```",
+			out:  "before\n\nx := **1**",
+		},
+		{
+			name: "html",
+			in:   "a <b>b</b> c",
+			out:  "a b c",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := plainText([]byte(test.in)); got != test.out {
+				t.Errorf("unexpected plain text:\n got %q\nwant %q", got, test.out)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package md
+
+import (
+	"sync"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+
+	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
+)
+
+// KindSpoiler is the NodeKind of the Spoiler node.
+var KindSpoiler = ast.NewNodeKind("Spoiler")
+
+// Spoiler is an inline node of text that is concealed until the user reveals
+// it. In Markdown, spoilers are written as ||spoiler||, the same as Discord.
+// Matrix sends them as <span data-mx-spoiler>.
+type Spoiler struct {
+	ast.BaseInline
+}
+
+// Kind implements ast.Node.
+func (n *Spoiler) Kind() ast.NodeKind { return KindSpoiler }
+
+// Dump implements ast.Node.
+func (n *Spoiler) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+type spoilerDelimiterProcessor struct{}
+
+func (p spoilerDelimiterProcessor) IsDelimiter(b byte) bool { return b == '|' }
+
+func (p spoilerDelimiterProcessor) CanOpenCloser(opener, closer *parser.Delimiter) bool {
+	return opener.Char == closer.Char
+}
+
+func (p spoilerDelimiterProcessor) OnMatch(consumes int) ast.Node {
+	return &Spoiler{}
+}
+
+type spoilerParser struct{}
+
+// NewSpoilerParser returns a new InlineParser that parses ||spoiler||
+// expressions into Spoiler nodes.
+func NewSpoilerParser() parser.InlineParser {
+	return spoilerParser{}
+}
+
+func (p spoilerParser) Trigger() []byte {
+	return []byte{'|'}
+}
+
+func (p spoilerParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	before := block.PrecendingCharacter()
+	line, segment := block.PeekLine()
+
+	node := parser.ScanDelimiter(line, before, 2, spoilerDelimiterProcessor{})
+	if node == nil || node.OriginalLength != 2 {
+		return nil
+	}
+
+	node.Segment = segment.WithStop(segment.Start + node.OriginalLength)
+	block.Advance(node.OriginalLength)
+	pc.PushDelimiter(node)
+	return node
+}
+
+type spoilerHTMLRenderer struct{}
+
+// NewSpoilerHTMLRenderer returns a new NodeRenderer that renders Spoiler nodes
+// as Matrix spoilers.
+func NewSpoilerHTMLRenderer() renderer.NodeRenderer {
+	return spoilerHTMLRenderer{}
+}
+
+// RegisterFuncs implements renderer.NodeRenderer.
+func (r spoilerHTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindSpoiler, r.renderSpoiler)
+}
+
+func (r spoilerHTMLRenderer) renderSpoiler(w util.BufWriter, src []byte, n ast.Node, enter bool) (ast.WalkStatus, error) {
+	if enter {
+		w.WriteString("<span data-mx-spoiler>")
+	} else {
+		w.WriteString("</span>")
+	}
+	return ast.WalkContinue, nil
+}
+
+// SpoilerTag returns the tag that conceals spoilers from the given table. The
+// tag is raised above all other tags in the table, so it also conceals the
+// colors of other tags, such as links. Use AddSpoiler to conceal text, so
+// that each spoiler is revealed on its own.
+func SpoilerTag(table *gtk.TextTagTable) *gtk.TextTag {
+	tag := Tags.FromTable(table, "_spoiler")
+	tag.SetPriority(table.Size() - 1)
+	return tag
+}
+
+// IsConcealed returns true if the given iterator is within a spoiler that is
+// not yet revealed.
+func IsConcealed(iter *gtk.TextIter) bool {
+	tag := iter.Buffer().TagTable().Lookup("_spoiler")
+	return tag != nil && iter.HasTag(tag)
+}
+
+// spoilerRange is a spoiler within a buffer. Its bounds are kept as marks, so
+// they stay correct when the buffer is edited.
+type spoilerRange struct {
+	start *gtk.TextMark
+	end   *gtk.TextMark
+}
+
+// spoilerIndex is the side table of concealed spoilers within a buffer. All
+// spoilers share the same tag, so back-to-back spoilers can only be told apart
+// using the index.
+type spoilerIndex struct {
+	spoilers []spoilerRange
+}
+
+var (
+	spoilerIndexMu sync.Mutex
+	spoilerIndexes = map[uintptr]*spoilerIndex{}
+)
+
+// bufferSpoilerIndex returns the spoiler index of the given buffer. If create
+// is true, then a new index is created if the buffer doesn't have one yet.
+// Otherwise, nil is returned.
+func bufferSpoilerIndex(buf *gtk.TextBuffer, create bool) *spoilerIndex {
+	key := coreglib.InternObject(buf).Native()
+
+	spoilerIndexMu.Lock()
+	defer spoilerIndexMu.Unlock()
+
+	index, ok := spoilerIndexes[key]
+	if !ok && create {
+		index = &spoilerIndex{}
+		spoilerIndexes[key] = index
+
+		coreglib.WeakRefObject(buf, func() {
+			spoilerIndexMu.Lock()
+			delete(spoilerIndexes, key)
+			spoilerIndexMu.Unlock()
+		})
+	}
+
+	return index
+}
+
+// take removes the spoiler that contains the character at the given offset
+// from the index and returns its bounds.
+func (idx *spoilerIndex) take(buf *gtk.TextBuffer, offset int) (start, end int, ok bool) {
+	for i, spoiler := range idx.spoilers {
+		start = buf.IterAtMark(spoiler.start).Offset()
+		end = buf.IterAtMark(spoiler.end).Offset()
+		if start <= offset && offset < end {
+			idx.spoilers = append(idx.spoilers[:i], idx.spoilers[i+1:]...)
+			buf.DeleteMark(spoiler.start)
+			buf.DeleteMark(spoiler.end)
+			return start, end, true
+		}
+	}
+	return 0, 0, false
+}
+
+// AddSpoiler conceals the text between start and end in the given buffer as
+// one spoiler. Unlike applying SpoilerTag directly, spoilers added this way
+// are revealed separately even if they're right next to each other.
+func AddSpoiler(buf *gtk.TextBuffer, start, end *gtk.TextIter) {
+	index := bufferSpoilerIndex(buf, true)
+	index.spoilers = append(index.spoilers, spoilerRange{
+		// Text inserted at either edge of the spoiler is not part of it.
+		start: buf.CreateMark("", start, false),
+		end:   buf.CreateMark("", end, true),
+	})
+
+	buf.ApplyTag(SpoilerTag(buf.TagTable()), start, end)
+}
+
+// RevealSpoiler reveals the whole spoiler that the given iterator is in. False
+// is returned if the iterator is not within a concealed spoiler.
+func RevealSpoiler(iter *gtk.TextIter) bool {
+	if !IsConcealed(iter) {
+		return false
+	}
+
+	buf := iter.Buffer()
+	table := buf.TagTable()
+	concealed := table.Lookup("_spoiler")
+
+	var start, end *gtk.TextIter
+
+	if index := bufferSpoilerIndex(buf, false); index != nil {
+		if from, to, ok := index.take(buf, iter.Offset()); ok {
+			start = buf.IterAtOffset(from)
+			end = buf.IterAtOffset(to)
+		}
+	}
+
+	if start == nil {
+		// The spoiler was concealed using SpoilerTag, so its bounds are the
+		// toggles of the tag.
+		start = iter.Copy()
+		if !start.StartsTag(concealed) {
+			start.BackwardToTagToggle(concealed)
+		}
+
+		end = iter.Copy()
+		end.ForwardToTagToggle(concealed)
+	}
+
+	buf.RemoveTag(concealed, start, end)
+	buf.ApplyTag(Tags.FromTable(table, "spoiler"), start, end)
+	return true
+}
+
+// BindSpoilerHandler binds input handlers for revealing spoilers within the
+// TextView. Spoilers are revealed when clicked, or when Enter or Space is
+// pressed while the TextView is focused, in which case the spoiler under the
+// cursor or else the first spoiler is revealed. The TextView is made
+// focusable for this. If BindSpoilerHandler is called on the same TextView
+// again, then it does nothing. The function checks this by checking for the
+// .md-spoilered class.
+func BindSpoilerHandler(tview *gtk.TextView) {
+	if tview.HasCSSClass("md-spoilered") {
+		return
+	}
+	tview.AddCSSClass("md-spoilered")
+	tview.SetFocusable(true)
+
+	// Reveal on release, so the press that reveals a spoiler doesn't also
+	// activate the link handler.
+	click := gtk.NewGestureClick()
+	click.SetButton(1)
+	click.ConnectReleased(func(nPress int, x, y float64) {
+		bx, by := tview.WindowToBufferCoords(gtk.TextWindowWidget, int(x), int(y))
+		it, ok := tview.IterAtLocation(bx, by)
+		if ok {
+			RevealSpoiler(it)
+		}
+	})
+
+	key := gtk.NewEventControllerKey()
+	key.ConnectKeyPressed(func(keyval, _ uint, state gdk.ModifierType) bool {
+		switch keyval {
+		case gdk.KEY_Return, gdk.KEY_KP_Enter, gdk.KEY_space:
+			// ok
+		default:
+			return false
+		}
+
+		buf := tview.Buffer()
+		if RevealSpoiler(buf.IterAtMark(buf.GetInsert())) {
+			return true
+		}
+
+		tag := buf.TagTable().Lookup("_spoiler")
+		if tag == nil {
+			return false
+		}
+
+		it := buf.StartIter()
+		if !it.HasTag(tag) && !it.ForwardToTagToggle(tag) {
+			return false
+		}
+		return RevealSpoiler(it)
+	})
+
+	tview.AddController(click)
+	tview.AddController(key)
+}
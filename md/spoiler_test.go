@@ -0,0 +1,74 @@
+package md
+
+import (
+	"testing"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+func TestBindSpoilerHandler(t *testing.T) {
+	if !gtk.InitCheck() {
+		t.Skip("cannot initialize GTK")
+	}
+
+	buf := gtk.NewTextBuffer(nil)
+	buf.SetText("a secret b secret")
+	buf.ApplyTag(SpoilerTag(buf.TagTable()), buf.IterAtOffset(2), buf.IterAtOffset(8))
+	buf.ApplyTag(SpoilerTag(buf.TagTable()), buf.IterAtOffset(11), buf.IterAtOffset(17))
+
+	tview := gtk.NewTextViewWithBuffer(buf)
+	tview.SetFocusable(false)
+
+	BindSpoilerHandler(tview)
+	if !tview.Focusable() {
+		t.Fatal("TextView with spoilers is not focusable, so the keyboard can't reveal them")
+	}
+
+	if RevealSpoiler(buf.IterAtOffset(0)) {
+		t.Error("revealed a spoiler outside of one")
+	}
+	if !RevealSpoiler(buf.IterAtOffset(5)) {
+		t.Error("cannot reveal the first spoiler")
+	}
+
+	for offset, concealed := range map[int]bool{2: false, 7: false, 11: true, 16: true} {
+		if IsConcealed(buf.IterAtOffset(offset)) != concealed {
+			t.Errorf("offset %d: expected concealed = %v", offset, concealed)
+		}
+	}
+}
+
+func TestAddSpoiler(t *testing.T) {
+	if !gtk.InitCheck() {
+		t.Skip("cannot initialize GTK")
+	}
+
+	buf := gtk.NewTextBuffer(nil)
+	buf.SetText("> onetwo")
+	// Back-to-back spoilers share the same tag without a toggle between them.
+	AddSpoiler(buf, buf.IterAtOffset(2), buf.IterAtOffset(5))
+	AddSpoiler(buf, buf.IterAtOffset(5), buf.IterAtOffset(8))
+
+	// Spoilers move with the text around them.
+	buf.Delete(buf.IterAtOffset(0), buf.IterAtOffset(2))
+
+	if !RevealSpoiler(buf.IterAtOffset(4)) {
+		t.Fatal("cannot reveal the second spoiler")
+	}
+
+	for offset, concealed := range map[int]bool{0: true, 2: true, 3: false, 5: false} {
+		if IsConcealed(buf.IterAtOffset(offset)) != concealed {
+			t.Errorf("offset %d: expected concealed = %v", offset, concealed)
+		}
+	}
+
+	if !RevealSpoiler(buf.IterAtOffset(0)) {
+		t.Fatal("cannot reveal the first spoiler")
+	}
+	if IsConcealed(buf.IterAtOffset(1)) {
+		t.Error("first spoiler is still concealed")
+	}
+	if index := bufferSpoilerIndex(buf, false); len(index.spoilers) != 0 {
+		t.Errorf("revealed spoilers are still indexed: %d", len(index.spoilers))
+	}
+}